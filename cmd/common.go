@@ -5,7 +5,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -24,8 +26,14 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 			envName = "GHMLFS_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
 		}
 
-		// Check all possible sources
-		flagVal, _ := cmd.Flags().GetString(flagName)
+		// Check all possible sources. Non-string flags only count when set
+		// explicitly, as their defaults would otherwise shadow the environment.
+		flagVal := ""
+		if flag := cmd.Flags().Lookup(flagName); flag != nil {
+			if flag.Changed || flag.Value.Type() == "string" {
+				flagVal = flag.Value.String()
+			}
+		}
 		envVal := viper.GetString(envName)
 
 		value := ""
@@ -53,6 +61,26 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 	return values
 }
 
+// registerSecrets hands every configured secret to the redactor, whether it
+// comes from a flag, the environment or the .env file
+func registerSecrets(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if redact.IsSecretKey(flag.Name) {
+			redact.AddSecret(flag.Value.String())
+		}
+	})
+	for _, key := range viper.AllKeys() {
+		if redact.IsSecretKey(key) {
+			redact.AddSecret(viper.GetString(key))
+		}
+	}
+	for _, env := range os.Environ() {
+		if name, value, ok := strings.Cut(env, "="); ok && strings.HasPrefix(name, "GHMLFS_") && redact.IsSecretKey(name) {
+			redact.AddSecret(value)
+		}
+	}
+}
+
 func ShowConnectionStatus(actionType string) {
 	var endpoint string
 
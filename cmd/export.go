@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/mona-actions/gh-migrate-lfs/pkg/export"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -21,8 +22,8 @@ var exportCmd = &cobra.Command{
 		})
 
 		ShowConnectionStatus("export")
-		if err := export.ExportLFSRepos(); err != nil {
-			fmt.Printf("failed to export lfs: %v\n", err)
+		if err := export.ExportLFSRepos(cmd.Context()); err != nil {
+			fmt.Printf("failed to export lfs: %v\n", redact.Error(err))
 		}
 	},
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/migrate"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Runs export, pull and sync as a single pipelined migration",
+	Long:  "Runs export, pull and sync as a single pipelined migration. Repositories are synced as soon as their pull finishes, and completed phases are skipped when the migration is run again.",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_ADAPTIVE_WORKERS":      false,
+			"GHMLFS_ALLOW_INCOMPLETE_PUSH": false,
+			"GHMLFS_BLOB_LIMIT":            false,
+			"GHMLFS_BRANCH_MODE":           false,
+			"GHMLFS_EXCLUDE_REFS":          false,
+			"GHMLFS_FORCE":                 false,
+			"GHMLFS_INCLUDE_REFS":          false,
+			"GHMLFS_MAX_OBJECT_SIZE":       false,
+			"GHMLFS_MAX_WORKERS":           false,
+			"GHMLFS_MIN_WORKERS":           false,
+			"GHMLFS_OPERATION_TIMEOUT":     false,
+			"GHMLFS_OVERSIZED_POLICY":      false,
+			"GHMLFS_PULL_WORKERS":          false,
+			"GHMLFS_REFS_SINCE":            false,
+			"GHMLFS_REPO_TIMEOUT":          false,
+			"GHMLFS_REPORT":                false,
+			"GHMLFS_REPORT_FORMAT":         false,
+			"GHMLFS_SEARCH_DEPTH":          false,
+			"GHMLFS_SHARED_LFS_STORE":      false,
+			"GHMLFS_SKIP_VERIFY":           false,
+			"GHMLFS_SOURCE_HOSTNAME":       false,
+			"GHMLFS_SOURCE_ORGANIZATION":   true,
+			"GHMLFS_SOURCE_TOKEN":          true,
+			"GHMLFS_STALL_TIMEOUT":         false,
+			"GHMLFS_SYNC_WORKERS":          false,
+			"GHMLFS_TAG_ORDER":             false,
+			"GHMLFS_TARGET_HOSTNAME":       false,
+			"GHMLFS_TARGET_ORGANIZATION":   true,
+			"GHMLFS_TARGET_TOKEN":          true,
+			"GHMLFS_TIMEOUT_RETRIES":       false,
+			"GHMLFS_UNARCHIVE":             false,
+			"GHMLFS_WORK_DIR":              true,
+		})
+
+		ShowConnectionStatus("export")
+		ShowConnectionStatus("sync")
+		if err := migrate.MigrateLFS(cmd.Context()); err != nil {
+			fmt.Printf("failed to migrate lfs repositories: %v\n", redact.Error(err))
+		}
+	},
+}
+
+func init() {
+	migrateCmd.Flags().Bool("adaptive-workers", false, "Adjust the number of workers between --min-workers and --max-workers based on throttling and throughput")
+	migrateCmd.Flags().Bool("allow-incomplete-push", false, "Push the LFS objects present locally, skipping missing ones and listing them in a manifest")
+	migrateCmd.Flags().String("blob-limit", "", "Partial clone leaving out git blobs of this size or larger, e.g. 1m (default full clone)")
+	migrateCmd.Flags().BoolP("branch-mode", "b", false, "Branch based approach (default false)")
+	migrateCmd.Flags().String("exclude-refs", "", "Comma separated ref patterns whose LFS objects are not transferred, e.g. refs/pull/*")
+	migrateCmd.Flags().Bool("force", false, "Process repositories again even if already completed")
+	migrateCmd.Flags().String("include-refs", "", "Comma separated ref patterns whose LFS objects are transferred, e.g. refs/heads/*,refs/tags/* (default all refs)")
+	migrateCmd.Flags().String("max-object-size", "", "Largest LFS object the target accepts, e.g. 2g, checked before pushing (default no limit)")
+	migrateCmd.Flags().Int("max-workers", 8, "Maximum number of workers in adaptive mode")
+	migrateCmd.Flags().Int("min-workers", 1, "Minimum number of workers in adaptive mode")
+	migrateCmd.Flags().String("operation-timeout", "", "Maximum duration of a single git command, e.g. 2h (default no limit)")
+	migrateCmd.Flags().String("oversized-policy", "", "What sync does with LFS objects above --max-object-size: fail or skip (default fail)")
+	migrateCmd.Flags().Int("pull-workers", 1, "Number of concurrent GIT workers to use for pull")
+	migrateCmd.Flags().String("refs-since", "", "Only transfer LFS objects of refs updated since this date, e.g. 2023-01-31")
+	migrateCmd.Flags().String("repo-timeout", "", "Maximum duration of all work on one repository, e.g. 6h (default no limit)")
+	migrateCmd.Flags().String("report", "", "Write a run report to this file (json, csv or md)")
+	migrateCmd.Flags().String("report-format", "", "Report format: json, csv or markdown (default from file extension)")
+	migrateCmd.Flags().StringP("search-depth", "s", "", "Search depth for .gitattributes file")
+	migrateCmd.Flags().Bool("shared-lfs-store", false, "Keep one copy of each LFS object for all repositories of the work dir, linked into each repository")
+	migrateCmd.Flags().Bool("skip-verify", false, "Do not re-hash local LFS objects before pushing")
+	migrateCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	migrateCmd.Flags().String("source-organization", "", "Source organization (required)")
+	migrateCmd.Flags().String("source-token", "", "Source GitHub token with repo scope (required)")
+	migrateCmd.Flags().String("stall-timeout", "", "Stop a transfer that made no progress for this long, e.g. 30m (default no limit)")
+	migrateCmd.Flags().Int("sync-workers", 1, "Number of concurrent GIT workers to use for sync")
+	migrateCmd.Flags().String("tag-order", "", "When branch mode pushes tags: before-branches, after-branches or none (default before-branches)")
+	migrateCmd.Flags().String("target-hostname", "", "Target GitHub Enterprise Server hostname URL (optional)")
+	migrateCmd.Flags().String("target-organization", "", "Target organization (required)")
+	migrateCmd.Flags().String("target-token", "", "Target GitHub token with repo scope (required)")
+	migrateCmd.Flags().Int("timeout-retries", 1, "Number of times a repository that timed out or stalled is retried")
+	migrateCmd.Flags().Bool("unarchive", false, "Temporarily unarchive archived target repositories during sync")
+	migrateCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+
+	viper.BindPFlag("GHMLFS_ADAPTIVE_WORKERS", migrateCmd.Flags().Lookup("adaptive-workers"))
+	viper.BindPFlag("GHMLFS_ALLOW_INCOMPLETE_PUSH", migrateCmd.Flags().Lookup("allow-incomplete-push"))
+	viper.BindPFlag("GHMLFS_BLOB_LIMIT", migrateCmd.Flags().Lookup("blob-limit"))
+	viper.BindPFlag("GHMLFS_BRANCH_MODE", migrateCmd.Flags().Lookup("branch-mode"))
+	viper.BindPFlag("GHMLFS_EXCLUDE_REFS", migrateCmd.Flags().Lookup("exclude-refs"))
+	viper.BindPFlag("GHMLFS_FORCE", migrateCmd.Flags().Lookup("force"))
+	viper.BindPFlag("GHMLFS_INCLUDE_REFS", migrateCmd.Flags().Lookup("include-refs"))
+	viper.BindPFlag("GHMLFS_MAX_OBJECT_SIZE", migrateCmd.Flags().Lookup("max-object-size"))
+	viper.BindPFlag("GHMLFS_MAX_WORKERS", migrateCmd.Flags().Lookup("max-workers"))
+	viper.BindPFlag("GHMLFS_MIN_WORKERS", migrateCmd.Flags().Lookup("min-workers"))
+	viper.BindPFlag("GHMLFS_OPERATION_TIMEOUT", migrateCmd.Flags().Lookup("operation-timeout"))
+	viper.BindPFlag("GHMLFS_OVERSIZED_POLICY", migrateCmd.Flags().Lookup("oversized-policy"))
+	viper.BindPFlag("GHMLFS_PULL_WORKERS", migrateCmd.Flags().Lookup("pull-workers"))
+	viper.BindPFlag("GHMLFS_REFS_SINCE", migrateCmd.Flags().Lookup("refs-since"))
+	viper.BindPFlag("GHMLFS_REPO_TIMEOUT", migrateCmd.Flags().Lookup("repo-timeout"))
+	viper.BindPFlag("GHMLFS_REPORT", migrateCmd.Flags().Lookup("report"))
+	viper.BindPFlag("GHMLFS_REPORT_FORMAT", migrateCmd.Flags().Lookup("report-format"))
+	viper.BindPFlag("GHMLFS_SEARCH_DEPTH", migrateCmd.Flags().Lookup("search-depth"))
+	viper.BindPFlag("GHMLFS_SHARED_LFS_STORE", migrateCmd.Flags().Lookup("shared-lfs-store"))
+	viper.BindPFlag("GHMLFS_SKIP_VERIFY", migrateCmd.Flags().Lookup("skip-verify"))
+	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", migrateCmd.Flags().Lookup("source-hostname"))
+	viper.BindPFlag("GHMLFS_SOURCE_ORGANIZATION", migrateCmd.Flags().Lookup("source-organization"))
+	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", migrateCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_STALL_TIMEOUT", migrateCmd.Flags().Lookup("stall-timeout"))
+	viper.BindPFlag("GHMLFS_SYNC_WORKERS", migrateCmd.Flags().Lookup("sync-workers"))
+	viper.BindPFlag("GHMLFS_TAG_ORDER", migrateCmd.Flags().Lookup("tag-order"))
+	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", migrateCmd.Flags().Lookup("target-hostname"))
+	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", migrateCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMLFS_TARGET_TOKEN", migrateCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_TIMEOUT_RETRIES", migrateCmd.Flags().Lookup("timeout-retries"))
+	viper.BindPFlag("GHMLFS_UNARCHIVE", migrateCmd.Flags().Lookup("unarchive"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", migrateCmd.Flags().Lookup("work-dir"))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/mona-actions/gh-migrate-lfs/pkg/pull"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,34 +14,85 @@ var pullCmd = &cobra.Command{
 	Short: "Does a git clone and lfs pull on exported repositories",
 	Long:  "Does a git clone and lfs pull on exported repositories",
 	Run: func(cmd *cobra.Command, args []string) {
+		// The inventory file is not needed when retrying a failures file
+		retrying := cmd.Flags().Changed("retry-failed") || viper.GetString("GHMLFS_RETRY_FAILED") != ""
+
 		GetFlagOrEnv(cmd, map[string]bool{
-			"GHMLFS_BRANCH_MODE":     false,
-			"GHMLFS_FILE":            true,
-			"GHMLFS_SOURCE_HOSTNAME": false,
-			"GHMLFS_SOURCE_TOKEN":    true,
-			"GHMLFS_WORK_DIR":        true,
-			"GHMLFS_WORKERS":         false,
+			"GHMLFS_ADAPTIVE_WORKERS":  false,
+			"GHMLFS_BLOB_LIMIT":        false,
+			"GHMLFS_BRANCH_MODE":       false,
+			"GHMLFS_EXCLUDE_REFS":      false,
+			"GHMLFS_FILE":              !retrying,
+			"GHMLFS_FORCE":             false,
+			"GHMLFS_INCLUDE_REFS":      false,
+			"GHMLFS_MAX_WORKERS":       false,
+			"GHMLFS_MIN_WORKERS":       false,
+			"GHMLFS_OPERATION_TIMEOUT": false,
+			"GHMLFS_REFS_SINCE":        false,
+			"GHMLFS_REPO_TIMEOUT":      false,
+			"GHMLFS_REPORT":            false,
+			"GHMLFS_REPORT_FORMAT":     false,
+			"GHMLFS_RETRY_FAILED":      false,
+			"GHMLFS_SHARED_LFS_STORE":  false,
+			"GHMLFS_SOURCE_HOSTNAME":   false,
+			"GHMLFS_SOURCE_TOKEN":      true,
+			"GHMLFS_STALL_TIMEOUT":     false,
+			"GHMLFS_TIMEOUT_RETRIES":   false,
+			"GHMLFS_WORK_DIR":          true,
+			"GHMLFS_WORKERS":           false,
 		})
 
 		ShowConnectionStatus("pull")
-		if err := pull.PullLFSFromCSV(); err != nil {
-			fmt.Printf("failed to export lfs repositories: %v\n", err)
+		if err := pull.PullLFSFromCSV(cmd.Context()); err != nil {
+			fmt.Printf("failed to export lfs repositories: %v\n", redact.Error(err))
 		}
 	},
 }
 
 func init() {
+	pullCmd.Flags().Bool("adaptive-workers", false, "Adjust the number of workers between --min-workers and --max-workers based on throttling and throughput")
+	pullCmd.Flags().String("blob-limit", "", "Partial clone leaving out git blobs of this size or larger, e.g. 1m (default full clone)")
 	pullCmd.Flags().BoolP("branch-mode", "b", false, "Branch based approach (default false)")
+	pullCmd.Flags().String("exclude-refs", "", "Comma separated ref patterns whose LFS objects are not transferred, e.g. refs/pull/*")
 	pullCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
+	pullCmd.Flags().Bool("force", false, "Process repositories again even if already completed")
+	pullCmd.Flags().String("include-refs", "", "Comma separated ref patterns whose LFS objects are transferred, e.g. refs/heads/*,refs/tags/* (default all refs)")
+	pullCmd.Flags().Int("max-workers", 8, "Maximum number of workers in adaptive mode")
+	pullCmd.Flags().Int("min-workers", 1, "Minimum number of workers in adaptive mode")
+	pullCmd.Flags().String("operation-timeout", "", "Maximum duration of a single git command, e.g. 2h (default no limit)")
+	pullCmd.Flags().String("refs-since", "", "Only transfer LFS objects of refs updated since this date, e.g. 2023-01-31")
+	pullCmd.Flags().String("repo-timeout", "", "Maximum duration of all work on one repository, e.g. 6h (default no limit)")
+	pullCmd.Flags().String("report", "", "Write a run report to this file (json, csv or md)")
+	pullCmd.Flags().String("report-format", "", "Report format: json, csv or markdown (default from file extension)")
+	pullCmd.Flags().String("retry-failed", "", "Failures file from a previous run, re-runs only the repositories it lists")
+	pullCmd.Flags().Bool("shared-lfs-store", false, "Keep one copy of each LFS object for all repositories of the work dir, linked into each repository")
 	pullCmd.Flags().StringP("source-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
 	pullCmd.Flags().StringP("source-token", "t", "", "GitHub token with repo scope (required)")
+	pullCmd.Flags().String("stall-timeout", "", "Stop a transfer that made no progress for this long, e.g. 30m (default no limit)")
+	pullCmd.Flags().Int("timeout-retries", 1, "Number of times a repository that timed out or stalled is retried")
 	pullCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
 	pullCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
 
+	viper.BindPFlag("GHMLFS_ADAPTIVE_WORKERS", pullCmd.Flags().Lookup("adaptive-workers"))
+	viper.BindPFlag("GHMLFS_BLOB_LIMIT", pullCmd.Flags().Lookup("blob-limit"))
 	viper.BindPFlag("GHMLFS_BRANCH_MODE", pullCmd.Flags().Lookup("branch-mode"))
+	viper.BindPFlag("GHMLFS_EXCLUDE_REFS", pullCmd.Flags().Lookup("exclude-refs"))
 	viper.BindPFlag("GHMLFS_FILE", pullCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_FORCE", pullCmd.Flags().Lookup("force"))
+	viper.BindPFlag("GHMLFS_INCLUDE_REFS", pullCmd.Flags().Lookup("include-refs"))
+	viper.BindPFlag("GHMLFS_MAX_WORKERS", pullCmd.Flags().Lookup("max-workers"))
+	viper.BindPFlag("GHMLFS_MIN_WORKERS", pullCmd.Flags().Lookup("min-workers"))
+	viper.BindPFlag("GHMLFS_OPERATION_TIMEOUT", pullCmd.Flags().Lookup("operation-timeout"))
+	viper.BindPFlag("GHMLFS_REFS_SINCE", pullCmd.Flags().Lookup("refs-since"))
+	viper.BindPFlag("GHMLFS_REPO_TIMEOUT", pullCmd.Flags().Lookup("repo-timeout"))
+	viper.BindPFlag("GHMLFS_REPORT", pullCmd.Flags().Lookup("report"))
+	viper.BindPFlag("GHMLFS_REPORT_FORMAT", pullCmd.Flags().Lookup("report-format"))
+	viper.BindPFlag("GHMLFS_RETRY_FAILED", pullCmd.Flags().Lookup("retry-failed"))
+	viper.BindPFlag("GHMLFS_SHARED_LFS_STORE", pullCmd.Flags().Lookup("shared-lfs-store"))
 	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", pullCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", pullCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_STALL_TIMEOUT", pullCmd.Flags().Lookup("stall-timeout"))
+	viper.BindPFlag("GHMLFS_TIMEOUT_RETRIES", pullCmd.Flags().Lookup("timeout-retries"))
 	viper.BindPFlag("GHMLFS_WORK_DIR", pullCmd.Flags().Lookup("work-dir"))
 	viper.BindPFlag("GHMLFS_WORKERS", pullCmd.Flags().Lookup("workers"))
 }
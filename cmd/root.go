@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -11,10 +14,23 @@ var rootCmd = &cobra.Command{
 	Use:   "migrate-lfs",
 	Short: "gh cli extension to migrate LFS files between git repositories",
 	Long:  "gh cli extension to migrate LFS files between git repositories",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		registerSecrets(cmd)
+		return logger.Setup(
+			viper.GetString("GHMLFS_LOG_LEVEL"),
+			viper.GetString("GHMLFS_LOG_FORMAT"),
+			viper.GetString("GHMLFS_LOG_FILE"),
+		)
+	},
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	defer logger.Close()
+
+	ctx, stop := common.NotifyShutdown(context.Background())
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -26,6 +42,13 @@ func init() {
 	rootCmd.PersistentFlags().String("no-proxy", "", "No proxy list")
 	rootCmd.PersistentFlags().Int("retry-max", 3, "Maximum retry attempts")
 	rootCmd.PersistentFlags().String("retry-delay", "1s", "Delay between retries")
+	rootCmd.PersistentFlags().Int("api-rate-limit", 0, "Maximum GitHub API requests per hour and host, 0 for no limit")
+	rootCmd.PersistentFlags().Int("api-concurrency", 10, "Maximum concurrent GitHub API requests per host")
+	rootCmd.PersistentFlags().String("http-cache", "", "Directory caching GitHub API responses for conditional requests (default no cache)")
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().String("log-file", "", "Write logs to this file instead of stderr")
+	rootCmd.PersistentFlags().Bool("debug", false, "Run git with tracing enabled and write redacted trace logs per repository to <work-dir>/debug")
 
 	// Bind flags to viper
 	viper.BindPFlag("HTTP_PROXY", rootCmd.PersistentFlags().Lookup("http-proxy"))
@@ -33,11 +56,21 @@ func init() {
 	viper.BindPFlag("NO_PROXY", rootCmd.PersistentFlags().Lookup("no-proxy"))
 	viper.BindPFlag("RETRY_MAX", rootCmd.PersistentFlags().Lookup("retry-max"))
 	viper.BindPFlag("RETRY_DELAY", rootCmd.PersistentFlags().Lookup("retry-delay"))
+	viper.BindPFlag("GHMLFS_API_RATE_LIMIT", rootCmd.PersistentFlags().Lookup("api-rate-limit"))
+	viper.BindPFlag("GHMLFS_API_CONCURRENCY", rootCmd.PersistentFlags().Lookup("api-concurrency"))
+	viper.BindPFlag("GHMLFS_HTTP_CACHE", rootCmd.PersistentFlags().Lookup("http-cache"))
+	viper.BindPFlag("GHMLFS_LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("GHMLFS_LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("GHMLFS_LOG_FILE", rootCmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("GHMLFS_DEBUG", rootCmd.PersistentFlags().Lookup("debug"))
 
 	// Add subcommands
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(pullCmd)
 	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(verifyLocalCmd)
 
 	// hide -h, --help from global/proxy flags
 	rootCmd.Flags().BoolP("help", "h", false, "")
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/status"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the migration state of repositories in the working directory",
+	Long:  "Shows the migration state of repositories in the working directory",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_WORK_DIR": true,
+		})
+
+		if err := status.ShowStatus(); err != nil {
+			fmt.Printf("failed to show status: %v\n", redact.Error(err))
+		}
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+
+	viper.BindPFlag("GHMLFS_WORK_DIR", statusCmd.Flags().Lookup("work-dir"))
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
 	"github.com/mona-actions/gh-migrate-lfs/pkg/sync"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,36 +14,100 @@ var syncCmd = &cobra.Command{
 	Short: "Sync LFS objects to migrated repositories",
 	Long:  "Sync LFS objects to migrated repositories",
 	Run: func(cmd *cobra.Command, args []string) {
+		// The inventory file is not needed when retrying a failures file
+		retrying := cmd.Flags().Changed("retry-failed") || viper.GetString("GHMLFS_RETRY_FAILED") != ""
+
 		GetFlagOrEnv(cmd, map[string]bool{
-			"GHMLFS_BRANCH_MODE":         false,
-			"GHMLFS_FILE":                true,
-			"GHMLFS_TARGET_HOSTNAME":     false,
-			"GHMLFS_TARGET_ORGANIZATION": true,
-			"GHMLFS_TARGET_TOKEN":        true,
-			"GHMLFS_WORK_DIR":            true,
-			"GHMLFS_WORKERS":             false,
+			"GHMLFS_ADAPTIVE_WORKERS":      false,
+			"GHMLFS_ALLOW_INCOMPLETE_PUSH": false,
+			"GHMLFS_BRANCH_MODE":           false,
+			"GHMLFS_EXCLUDE_REFS":          false,
+			"GHMLFS_FILE":                  !retrying,
+			"GHMLFS_FORCE":                 false,
+			"GHMLFS_INCLUDE_REFS":          false,
+			"GHMLFS_MAX_OBJECT_SIZE":       false,
+			"GHMLFS_MAX_WORKERS":           false,
+			"GHMLFS_MIN_WORKERS":           false,
+			"GHMLFS_OPERATION_TIMEOUT":     false,
+			"GHMLFS_OVERSIZED_POLICY":      false,
+			"GHMLFS_REFS_SINCE":            false,
+			"GHMLFS_REPO_TIMEOUT":          false,
+			"GHMLFS_REPORT":                false,
+			"GHMLFS_REPORT_FORMAT":         false,
+			"GHMLFS_RETRY_FAILED":          false,
+			"GHMLFS_SKIP_VERIFY":           false,
+			"GHMLFS_STALL_TIMEOUT":         false,
+			"GHMLFS_TAG_ORDER":             false,
+			"GHMLFS_TARGET_HOSTNAME":       false,
+			"GHMLFS_TARGET_ORGANIZATION":   true,
+			"GHMLFS_TARGET_TOKEN":          true,
+			"GHMLFS_TIMEOUT_RETRIES":       false,
+			"GHMLFS_UNARCHIVE":             false,
+			"GHMLFS_WORK_DIR":              true,
+			"GHMLFS_WORKERS":               false,
 		})
 
 		ShowConnectionStatus("sync")
-		if err := sync.SyncFromCSV(); err != nil {
-			fmt.Printf("failed to sync repositories: %v\n", err)
+		if err := sync.SyncFromCSV(cmd.Context()); err != nil {
+			fmt.Printf("failed to sync repositories: %v\n", redact.Error(err))
 		}
 	},
 }
 
 func init() {
+	syncCmd.Flags().Bool("adaptive-workers", false, "Adjust the number of workers between --min-workers and --max-workers based on throttling and throughput")
+	syncCmd.Flags().Bool("allow-incomplete-push", false, "Push the LFS objects present locally, skipping missing ones and listing them in a manifest")
+	syncCmd.Flags().BoolP("branch-mode", "b", false, "Branch based approach (default false)")
+	syncCmd.Flags().String("exclude-refs", "", "Comma separated ref patterns whose LFS objects are not transferred, e.g. refs/pull/*")
 	syncCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
+	syncCmd.Flags().Bool("force", false, "Process repositories again even if already completed")
+	syncCmd.Flags().String("include-refs", "", "Comma separated ref patterns whose LFS objects are transferred, e.g. refs/heads/*,refs/tags/* (default all refs)")
+	syncCmd.Flags().String("max-object-size", "", "Largest LFS object the target accepts, e.g. 2g, checked before pushing (default no limit)")
+	syncCmd.Flags().Int("max-workers", 8, "Maximum number of workers in adaptive mode")
+	syncCmd.Flags().Int("min-workers", 1, "Minimum number of workers in adaptive mode")
+	syncCmd.Flags().String("operation-timeout", "", "Maximum duration of a single git command, e.g. 2h (default no limit)")
+	syncCmd.Flags().String("oversized-policy", "", "What sync does with LFS objects above --max-object-size: fail or skip (default fail)")
+	syncCmd.Flags().String("refs-since", "", "Only transfer LFS objects of refs updated since this date, e.g. 2023-01-31")
+	syncCmd.Flags().String("repo-timeout", "", "Maximum duration of all work on one repository, e.g. 6h (default no limit)")
+	syncCmd.Flags().String("report", "", "Write a run report to this file (json, csv or md)")
+	syncCmd.Flags().String("report-format", "", "Report format: json, csv or markdown (default from file extension)")
+	syncCmd.Flags().String("retry-failed", "", "Failures file from a previous run, re-runs only the repositories it lists")
+	syncCmd.Flags().Bool("skip-verify", false, "Do not re-hash local LFS objects before pushing")
+	syncCmd.Flags().String("stall-timeout", "", "Stop a transfer that made no progress for this long, e.g. 30m (default no limit)")
+	syncCmd.Flags().String("tag-order", "", "When branch mode pushes tags: before-branches, after-branches or none (default before-branches)")
 	syncCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
 	syncCmd.Flags().StringP("target-organization", "o", "", "Organization (required)")
 	syncCmd.Flags().StringP("target-token", "t", "", "GitHub token with repo scope (required)")
+	syncCmd.Flags().Int("timeout-retries", 1, "Number of times a repository that timed out or stalled is retried")
+	syncCmd.Flags().Bool("unarchive", false, "Temporarily unarchive archived target repositories during sync")
 	syncCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
 	syncCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
 
+	viper.BindPFlag("GHMLFS_ADAPTIVE_WORKERS", syncCmd.Flags().Lookup("adaptive-workers"))
+	viper.BindPFlag("GHMLFS_ALLOW_INCOMPLETE_PUSH", syncCmd.Flags().Lookup("allow-incomplete-push"))
 	viper.BindPFlag("GHMLFS_BRANCH_MODE", syncCmd.Flags().Lookup("branch-mode"))
+	viper.BindPFlag("GHMLFS_EXCLUDE_REFS", syncCmd.Flags().Lookup("exclude-refs"))
 	viper.BindPFlag("GHMLFS_FILE", syncCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_FORCE", syncCmd.Flags().Lookup("force"))
+	viper.BindPFlag("GHMLFS_INCLUDE_REFS", syncCmd.Flags().Lookup("include-refs"))
+	viper.BindPFlag("GHMLFS_MAX_OBJECT_SIZE", syncCmd.Flags().Lookup("max-object-size"))
+	viper.BindPFlag("GHMLFS_MAX_WORKERS", syncCmd.Flags().Lookup("max-workers"))
+	viper.BindPFlag("GHMLFS_MIN_WORKERS", syncCmd.Flags().Lookup("min-workers"))
+	viper.BindPFlag("GHMLFS_OPERATION_TIMEOUT", syncCmd.Flags().Lookup("operation-timeout"))
+	viper.BindPFlag("GHMLFS_OVERSIZED_POLICY", syncCmd.Flags().Lookup("oversized-policy"))
+	viper.BindPFlag("GHMLFS_REFS_SINCE", syncCmd.Flags().Lookup("refs-since"))
+	viper.BindPFlag("GHMLFS_REPO_TIMEOUT", syncCmd.Flags().Lookup("repo-timeout"))
+	viper.BindPFlag("GHMLFS_REPORT", syncCmd.Flags().Lookup("report"))
+	viper.BindPFlag("GHMLFS_REPORT_FORMAT", syncCmd.Flags().Lookup("report-format"))
+	viper.BindPFlag("GHMLFS_RETRY_FAILED", syncCmd.Flags().Lookup("retry-failed"))
+	viper.BindPFlag("GHMLFS_SKIP_VERIFY", syncCmd.Flags().Lookup("skip-verify"))
+	viper.BindPFlag("GHMLFS_STALL_TIMEOUT", syncCmd.Flags().Lookup("stall-timeout"))
+	viper.BindPFlag("GHMLFS_TAG_ORDER", syncCmd.Flags().Lookup("tag-order"))
 	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", syncCmd.Flags().Lookup("target-hostname"))
 	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", syncCmd.Flags().Lookup("target-organization"))
 	viper.BindPFlag("GHMLFS_TARGET_TOKEN", syncCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_TIMEOUT_RETRIES", syncCmd.Flags().Lookup("timeout-retries"))
+	viper.BindPFlag("GHMLFS_UNARCHIVE", syncCmd.Flags().Lookup("unarchive"))
 	viper.BindPFlag("GHMLFS_WORK_DIR", syncCmd.Flags().Lookup("work-dir"))
 	viper.BindPFlag("GHMLFS_WORKERS", syncCmd.Flags().Lookup("workers"))
 }
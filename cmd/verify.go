@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/verify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var verifyLocalCmd = &cobra.Command{
+	Use:   "verify-local",
+	Short: "Checks that local LFS objects match their OID, quarantining corrupt ones",
+	Long:  "Re-hashes every local LFS object with SHA-256, quarantines the ones whose content does not match their OID and queues them to be fetched again by the next pull",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_FILE":          false,
+			"GHMLFS_REPORT":        false,
+			"GHMLFS_REPORT_FORMAT": false,
+			"GHMLFS_WORK_DIR":      true,
+			"GHMLFS_WORKERS":       false,
+		})
+
+		if err := verify.VerifyLocal(cmd.Context()); err != nil {
+			fmt.Printf("failed to verify local lfs objects: %v\n", redact.Error(err))
+		}
+	},
+}
+
+func init() {
+	verifyLocalCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (default all repositories in the work dir)")
+	verifyLocalCmd.Flags().String("report", "", "Write a run report to this file (json, csv or md)")
+	verifyLocalCmd.Flags().String("report-format", "", "Report format: json, csv or markdown (default from file extension)")
+	verifyLocalCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+	verifyLocalCmd.Flags().IntP("workers", "w", 1, "Number of concurrent workers to use")
+
+	viper.BindPFlag("GHMLFS_FILE", verifyLocalCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_REPORT", verifyLocalCmd.Flags().Lookup("report"))
+	viper.BindPFlag("GHMLFS_REPORT_FORMAT", verifyLocalCmd.Flags().Lookup("report-format"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", verifyLocalCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_WORKERS", verifyLocalCmd.Flags().Lookup("workers"))
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v66/github"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 )
@@ -28,13 +31,35 @@ func getHostname(hostname ...string) string {
 	return ""
 }
 
+type clientKey struct {
+	hostname string
+	token    string
+}
+
+var (
+	clients   = make(map[clientKey]*github.Client)
+	clientsMu sync.Mutex
+)
+
+// newGitHubClientWithHostname returns the client for a host and token. It is
+// created on first use and reused for the rest of the run, so that requests
+// share connections and the request limiter of the host.
 func newGitHubClientWithHostname(token string, hostname string) (*github.Client, error) {
-	client, err := newGitHubClientWithProxy(token, GetProxyConfigFromEnv())
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	key := clientKey{hostname: hostname, token: token}
+	if client, ok := clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := newGitHubClientWithProxy(token, GetProxyConfigFromEnv(), limiterFor(hostname))
 	if err != nil {
 		return nil, err
 	}
 
 	if hostname == "" {
+		clients[key] = client
 		return client, nil
 	}
 
@@ -48,10 +73,11 @@ func newGitHubClientWithHostname(token string, hostname string) (*github.Client,
 		return nil, fmt.Errorf("failed to configure enterprise URLs for %s: %w", hostname, err)
 	}
 
+	clients[key] = enterpriseClient
 	return enterpriseClient, nil
 }
 
-func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig) (*github.Client, error) {
+func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig, limiter *requestLimiter) (*github.Client, error) {
 	if token == "" {
 		return nil, fmt.Errorf("GitHub token is required")
 	}
@@ -85,9 +111,11 @@ func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig) (*github.C
 		},
 	}
 
+	// The cache sits outside the limiter, which sees the 304 answers to its
+	// revalidations
 	tc := oauth2.NewClient(ctx, ts)
 	tc.Transport = &oauth2.Transport{
-		Base:   transport,
+		Base:   newCachingTransport(&limitedTransport{base: transport, limiter: limiter}),
 		Source: ts,
 	}
 
@@ -102,7 +130,7 @@ func GetProxyConfigFromEnv() *ProxyConfig {
 	}
 }
 
-func retryOperation(operation func() error) error {
+func retryOperation(ctx context.Context, operation func() error) error {
 	maxRetries := viper.GetInt("MAX_RETRIES")
 	if maxRetries <= 0 {
 		maxRetries = 3 // fallback default
@@ -120,10 +148,19 @@ func retryOperation(operation func() error) error {
 			return nil
 		}
 
+		if ctx.Err() != nil {
+			return apiErr
+		}
+		common.ObserveError(ctx, apiErr)
+
 		if attempt < maxRetries {
 			waitTime := retryDelay * time.Duration(1<<uint(attempt-1))
-			fmt.Printf("Attempt %d failed, retrying in %v: %v\n", attempt, waitTime, apiErr)
-			time.Sleep(waitTime)
+			slog.Warn("API request failed, retrying", "attempt", attempt, "wait", waitTime, "error", apiErr)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return apiErr
+			}
 		}
 	}
 	return apiErr
@@ -138,13 +175,12 @@ func readContent(rc io.ReadCloser) (string, error) {
 	return string(content), nil
 }
 
-func CheckGitAttributes(org, repo, token string, depth int, hostname ...string) (bool, string, error) {
+func CheckGitAttributes(ctx context.Context, org, repo, token string, depth int, hostname ...string) (bool, string, error) {
 	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
 	if err != nil {
 		return false, "", fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
 
-	ctx := context.Background()
 	var foundPath string
 	var hasLFS bool
 
@@ -161,7 +197,7 @@ func CheckGitAttributes(org, repo, token string, depth int, hostname ...string)
 		}
 		visited[path] = true
 
-		err := retryOperation(func() error {
+		err := retryOperation(ctx, func() error {
 			opts := &github.RepositoryContentGetOptions{}
 
 			fileContent, dirContent, resp, err := client.Repositories.GetContents(ctx, org, repo, path, opts)
@@ -243,7 +279,7 @@ func CheckGitAttributes(org, repo, token string, depth int, hostname ...string)
 	return hasLFS, foundPath, nil
 }
 
-func GetRepositories(org, token string, hostname ...string) ([]string, error) {
+func GetRepositories(ctx context.Context, org, token string, hostname ...string) ([]string, error) {
 	if org == "" {
 		return nil, fmt.Errorf("organization name is required")
 	}
@@ -258,9 +294,9 @@ func GetRepositories(org, token string, hostname ...string) ([]string, error) {
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
-	err = retryOperation(func() error {
+	err = retryOperation(ctx, func() error {
 		for {
-			repos, resp, apiErr := client.Repositories.ListByOrg(context.Background(), org, opts)
+			repos, resp, apiErr := client.Repositories.ListByOrg(ctx, org, opts)
 			if apiErr != nil {
 				return apiErr
 			}
@@ -289,3 +325,47 @@ func GetRepositories(org, token string, hostname ...string) ([]string, error) {
 
 	return allRepos, nil
 }
+
+func GetRepository(ctx context.Context, org, repo, token string, hostname ...string) (*github.Repository, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var repository *github.Repository
+	err = retryOperation(ctx, func() error {
+		r, _, apiErr := client.Repositories.Get(ctx, org, repo)
+		if apiErr != nil {
+			return apiErr
+		}
+		repository = r
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s/%s: %w", org, repo, err)
+	}
+
+	return repository, nil
+}
+
+// SetRepositoryArchived archives or unarchives a repository
+func SetRepositoryArchived(ctx context.Context, org, repo, token string, archived bool, hostname ...string) error {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	err = retryOperation(ctx, func() error {
+		_, _, apiErr := client.Repositories.Edit(ctx, org, repo, &github.Repository{
+			Archived: github.Bool(archived),
+		})
+		return apiErr
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set archived=%t on %s/%s: %w", archived, org, repo, err)
+	}
+
+	return nil
+}
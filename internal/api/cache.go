@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// cacheMaxAge is how long an entry that is not revalidated is kept
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// cacheEntry is a cached GET response stored as one JSON file
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// cachingTransport stores successful GET responses that carry an ETag or
+// Last-Modified header, and revalidates them with conditional requests. A 304
+// answer is served from the cache and does not count against the REST API
+// rate limit.
+type cachingTransport struct {
+	base http.RoundTripper
+	dir  string
+}
+
+// newCachingTransport wraps base with the HTTP cache when a cache directory
+// is configured
+func newCachingTransport(base http.RoundTripper) http.RoundTripper {
+	dir := viper.GetString("GHMLFS_HTTP_CACHE")
+	if dir == "" {
+		return base
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		slog.Warn("HTTP cache disabled", "dir", dir, "error", err)
+		return base
+	}
+	pruneCache(dir, cacheMaxAge)
+	return &cachingTransport{base: base, dir: dir}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || hasQueryCredentials(req.URL) {
+		return t.base.RoundTrip(req)
+	}
+
+	path := t.path(req)
+	entry := readCacheEntry(path)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		resp.Body.Close()
+		slog.Debug("HTTP cache hit", "url", entry.URL)
+		// Entries still in use are not pruned
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return entry.response(req, resp), nil
+
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		writeCacheEntry(path, &cacheEntry{
+			URL:          req.URL.Redacted(),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Header:       resp.Header,
+			Body:         body,
+		})
+	}
+
+	return resp, nil
+}
+
+// hasQueryCredentials reports whether a URL carries a token or signature in
+// its query, such as raw download URLs. Such URLs change on every request, so
+// caching them would only fill the disk.
+func hasQueryCredentials(u *url.URL) bool {
+	for name := range u.Query() {
+		name = strings.ToLower(name)
+		switch {
+		case name == "token", name == "access_token", name == "jwt", name == "sig", name == "signature",
+			strings.HasPrefix(name, "x-amz-"), strings.HasPrefix(name, "x-goog-"):
+			return true
+		}
+	}
+	return false
+}
+
+// pruneCache removes the entries of dir not written or revalidated within
+// maxAge
+func pruneCache(dir string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			slog.Debug("failed to remove HTTP cache entry", "error", err)
+		}
+	}
+}
+
+// path names the cache file of a request. The credentials are part of the
+// key, as different tokens may see different content.
+func (t *cachingTransport) path(req *http.Request) string {
+	h := sha256.New()
+	for _, part := range []string{req.URL.String(), req.Header.Get("Accept"), req.Header.Get("Authorization")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return filepath.Join(t.dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// response rebuilds the cached response, keeping the rate limit headers of
+// the 304 answer
+func (e *cacheEntry) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.Header.Clone()
+	for name, values := range notModified.Header {
+		if strings.HasPrefix(name, "X-Ratelimit-") {
+			header[name] = values
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func readCacheEntry(path string) *cacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// writeCacheEntry replaces the cache file atomically, so that concurrent
+// readers never see a partial entry
+func writeCacheEntry(path string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		slog.Debug("failed to write HTTP cache entry", "error", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Debug("failed to write HTTP cache entry", "error", err)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// newCacheServer serves a body with an ETag, answering conditional requests
+// for it with 304 Not Modified
+func newCacheServer(t *testing.T, revalidated *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining", "100")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated.Add(1)
+			w.Header().Set("X-Ratelimit-Remaining", "99")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "content of "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newCacheClient(t *testing.T, dir string) *http.Client {
+	viper.Set("GHMLFS_HTTP_CACHE", dir)
+	t.Cleanup(func() { viper.Set("GHMLFS_HTTP_CACHE", "") })
+	return &http.Client{Transport: newCachingTransport(http.DefaultTransport)}
+}
+
+func get(t *testing.T, client *http.Client, url, auth string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func cacheFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestCachingTransportRevalidates(t *testing.T) {
+	var revalidated atomic.Int32
+	server := newCacheServer(t, &revalidated)
+	dir := t.TempDir()
+	client := newCacheClient(t, dir)
+
+	if _, body := get(t, client, server.URL+"/repos/mona/a", "token one"); body != "content of /repos/mona/a" {
+		t.Fatalf("first body = %q", body)
+	}
+	resp, body := get(t, client, server.URL+"/repos/mona/a", "token one")
+	if revalidated.Load() != 1 {
+		t.Fatalf("second request revalidated %d times, want 1", revalidated.Load())
+	}
+	if resp.StatusCode != http.StatusOK || body != "content of /repos/mona/a" {
+		t.Errorf("cached response = %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("X-Ratelimit-Remaining"); got != "99" {
+		t.Errorf("rate limit header = %q, want the one of the 304 answer", got)
+	}
+
+	// Another token does not see the entry
+	get(t, client, server.URL+"/repos/mona/a", "token two")
+	if revalidated.Load() != 1 {
+		t.Error("entry of another token was revalidated")
+	}
+	if n := cacheFiles(t, dir); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+}
+
+func TestCachingTransportSkipsCredentialURLs(t *testing.T) {
+	var revalidated atomic.Int32
+	server := newCacheServer(t, &revalidated)
+	dir := t.TempDir()
+	client := newCacheClient(t, dir)
+
+	for _, query := range []string{"?token=AAAA", "?X-Amz-Signature=abc&X-Amz-Credential=def", "?sig=xyz"} {
+		get(t, client, server.URL+"/mona/a/main/.gitattributes"+query, "")
+	}
+	if n := cacheFiles(t, dir); n != 0 {
+		t.Errorf("cache holds %d entries for URLs with credentials, want none", n)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/graphql", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if n := cacheFiles(t, dir); n != 0 {
+		t.Errorf("cache holds %d entries after a POST, want none", n)
+	}
+}
+
+func TestCachingTransportDisabled(t *testing.T) {
+	viper.Set("GHMLFS_HTTP_CACHE", "")
+	base := http.DefaultTransport
+	if transport := newCachingTransport(base); transport != base {
+		t.Error("cache enabled without a cache directory")
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.json")
+	recent := filepath.Join(dir, "recent.json")
+	for _, path := range []string{old, recent} {
+		if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * cacheMaxAge)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	pruneCache(dir, cacheMaxAge)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("old entry not pruned")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Error("recent entry pruned")
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultAPIConcurrency stays well below the 100 concurrent requests allowed
+// by GitHub's secondary rate limits
+const defaultAPIConcurrency = 10
+
+// requestLimiter throttles the requests sent to one host. A token bucket
+// refilled at the configured requests per hour bounds the rate, and a
+// semaphore bounds how many requests are in flight at once.
+type requestLimiter struct {
+	inFlight chan struct{}
+
+	mu       sync.Mutex
+	perSec   float64 // 0 when the rate is not limited
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+var (
+	limiters   = make(map[string]*requestLimiter)
+	limitersMu sync.Mutex
+)
+
+// limiterFor returns the limiter shared by all clients of a host, created
+// from the current settings on first use
+func limiterFor(hostname string) *requestLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if l, ok := limiters[hostname]; ok {
+		return l
+	}
+
+	l := newRequestLimiter(viper.GetInt("GHMLFS_API_CONCURRENCY"), viper.GetInt("GHMLFS_API_RATE_LIMIT"))
+	limiters[hostname] = l
+	return l
+}
+
+// newRequestLimiter returns a limiter allowing concurrency requests in flight,
+// defaultAPIConcurrency when not positive, and perHour requests per hour, no
+// limit when not positive
+func newRequestLimiter(concurrency, perHour int) *requestLimiter {
+	if concurrency <= 0 {
+		concurrency = defaultAPIConcurrency
+	}
+	l := &requestLimiter{inFlight: make(chan struct{}, concurrency), last: time.Now()}
+
+	if perHour > 0 {
+		l.perSec = float64(perHour) / time.Hour.Seconds()
+		// Allow a minute worth of requests in a burst
+		l.capacity = max(float64(perHour)/60, 1)
+		l.tokens = l.capacity
+	}
+	return l
+}
+
+// wait blocks until a request may be sent and returns the function that
+// frees its concurrency slot once the response is read
+func (l *requestLimiter) wait(ctx context.Context) (func(), error) {
+	if err := l.take(ctx); err != nil {
+		return nil, err
+	}
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return func() { <-l.inFlight }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// take removes a token from the bucket, waiting for one to be refilled
+func (l *requestLimiter) take(ctx context.Context) error {
+	if l.perSec == 0 {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.perSec)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.perSec * float64(time.Second))
+		l.mu.Unlock()
+
+		slog.Debug("API rate limit reached, waiting", "wait", delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// refund returns the token of a request that did not count against the rate
+// limit
+func (l *requestLimiter) refund() {
+	if l.perSec == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.capacity, l.tokens+1)
+}
+
+// limitedTransport sends requests through a requestLimiter. Revalidations of
+// cached responses answered with 304 Not Modified do not count against
+// GitHub's rate limit, so they do not use up the local one either.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *requestLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.limiter.wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		t.limiter.refund()
+	}
+
+	// The request is in flight until its body has been read
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: sync.OnceFunc(done)}
+	return resp, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
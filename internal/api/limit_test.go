@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiterTakeUnlimited(t *testing.T) {
+	l := newRequestLimiter(1, 0)
+	for range 100 {
+		if err := l.take(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRequestLimiterTakeWaitsForRefill(t *testing.T) {
+	// 72000 requests per hour refill one every 50ms, with a burst of 1200
+	l := newRequestLimiter(1, 72000)
+	l.tokens = 1
+
+	if err := l.take(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := l.take(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("take() with an empty bucket returned after %s", waited)
+	}
+}
+
+func TestRequestLimiterTakeCanceled(t *testing.T) {
+	l := newRequestLimiter(1, 1)
+	l.tokens = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.take(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("take() = %v, want the context error", err)
+	}
+}
+
+func TestRequestLimiterWaitBoundsInFlight(t *testing.T) {
+	l := newRequestLimiter(1, 0)
+
+	done, err := l.wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() with every slot in flight = %v, want the context error", err)
+	}
+
+	done()
+	if done, err := l.wait(context.Background()); err != nil {
+		t.Errorf("wait() after release = %v", err)
+	} else {
+		done()
+	}
+}
+
+func TestRequestLimiterRefund(t *testing.T) {
+	l := newRequestLimiter(1, 60)
+	l.tokens = 0.5
+	l.refund()
+	if l.tokens != 1 {
+		t.Errorf("tokens = %v after refund, want 1", l.tokens)
+	}
+	l.refund()
+	if l.tokens != l.capacity {
+		t.Errorf("tokens = %v, want at most the capacity %v", l.tokens, l.capacity)
+	}
+}
+
+func TestLimitedTransport(t *testing.T) {
+	status := http.StatusNotModified
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// One request per hour, so a second counted request would block
+	l := newRequestLimiter(1, 1)
+	client := &http.Client{Transport: &limitedTransport{base: http.DefaultTransport, limiter: l}}
+	get := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		return resp.Body.Close()
+	}
+
+	// 304 answers neither use up a token nor keep their slot
+	for range 3 {
+		if err := get(); err != nil {
+			t.Fatalf("revalidation blocked: %v", err)
+		}
+	}
+
+	status = http.StatusOK
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request over the rate limit = %v, want it to wait", err)
+	}
+}
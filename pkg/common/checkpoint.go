@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckpointDirName is the directory of the transfer checkpoints in the
+// working directory
+const CheckpointDirName = ".checkpoints"
+
+// Checkpoint records the work of a repository already transferred from a
+// source or to a target, so that an interrupted transfer resumes with the work
+// left: the LFS objects pushed by sync or fetched by pull. The file starts
+// with the source or target and lists one OID per line, appended as chunks
+// complete.
+type Checkpoint struct {
+	path   string
+	target string
+	done   map[string]bool
+	file   *os.File
+}
+
+func checkpointPath(workDir, repoName, phase string) string {
+	return filepath.Join(workDir, CheckpointDirName, repoName+"."+phase)
+}
+
+// OpenCheckpoint loads the checkpoint of a repository phase. A checkpoint
+// written for another source or target is discarded, as is a last line that
+// was not written completely.
+func OpenCheckpoint(workDir, repoName, phase, target string) (*Checkpoint, error) {
+	path := checkpointPath(workDir, repoName, phase)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	c := &Checkpoint{path: path, target: target, done: make(map[string]bool)}
+	if data, err := os.ReadFile(path); err == nil {
+		// Only lines ending in a newline were written completely
+		lines := strings.Split(string(data), "\n")
+		lines = lines[:len(lines)-1]
+		if len(lines) > 0 && strings.TrimPrefix(lines[0], "target ") == target {
+			for _, line := range lines[1:] {
+				if isOID(line) {
+					c.done[line] = true
+				}
+			}
+		}
+	}
+
+	// Rewrite the file so that a truncated last line or another target is dropped
+	var b strings.Builder
+	fmt.Fprintf(&b, "target %s\n", target)
+	for oid := range c.done {
+		fmt.Fprintln(&b, oid)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	c.file = file
+	return c, nil
+}
+
+// RemoveCheckpoint deletes the checkpoint of a repository phase, so that the
+// next transfer starts over
+func RemoveCheckpoint(workDir, repoName, phase string) error {
+	err := os.Remove(checkpointPath(workDir, repoName, phase))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Len returns the number of objects recorded as transferred
+func (c *Checkpoint) Len() int {
+	return len(c.done)
+}
+
+// Pending returns the objects among oids that were not transferred yet
+func (c *Checkpoint) Pending(oids []string) []string {
+	var pending []string
+	for _, oid := range oids {
+		if !c.done[oid] {
+			pending = append(pending, oid)
+		}
+	}
+	return pending
+}
+
+// Record marks objects as transferred, syncing the file so that the record
+// survives a crash
+func (c *Checkpoint) Record(oids []string) error {
+	var b strings.Builder
+	for _, oid := range oids {
+		if !c.done[oid] {
+			c.done[oid] = true
+			fmt.Fprintln(&b, oid)
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if _, err := c.file.WriteString(b.String()); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return c.file.Sync()
+}
+
+// Close closes the checkpoint, keeping it for the next run
+func (c *Checkpoint) Close() error {
+	return c.file.Close()
+}
+
+// Remove closes and deletes the checkpoint once the transfer is complete
+func (c *Checkpoint) Remove() error {
+	c.file.Close()
+	err := os.Remove(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ChunkObjects splits oids into chunks of at most maxCount objects and about
+// maxBytes of local content, for transfers recorded chunk by chunk
+func ChunkObjects(repoPath string, oids []string, maxCount int, maxBytes int64) [][]string {
+	objectsDir := LFSObjectsDir(repoPath)
+
+	var chunks [][]string
+	var chunk []string
+	var size int64
+	for _, oid := range oids {
+		if info, err := os.Stat(objectPath(objectsDir, oid)); err == nil {
+			size += info.Size()
+		}
+		chunk = append(chunk, oid)
+		if len(chunk) >= maxCount || size >= maxBytes {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
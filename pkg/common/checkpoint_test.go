@@ -0,0 +1,166 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testOIDs(n int) []string {
+	oids := make([]string, n)
+	for i := range oids {
+		oids[i] = strings.Repeat(string("0123456789abcdef"[i%16]), 64)
+	}
+	return oids
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	workDir := t.TempDir()
+	target := "https://github.com/mona-emu/repo.git"
+	oids := testOIDs(3)
+
+	c, err := OpenCheckpoint(workDir, "repo", "sync", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Record(oids[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Record([]string{oids[1], oids[2]}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = OpenCheckpoint(workDir, "repo", "sync", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", c.Len())
+	}
+	if got := c.Pending(append(oids, strings.Repeat("d", 64))); !reflect.DeepEqual(got, []string{strings.Repeat("d", 64)}) {
+		t.Errorf("Pending() = %v", got)
+	}
+}
+
+func TestCheckpointOtherTarget(t *testing.T) {
+	workDir := t.TempDir()
+	oids := testOIDs(2)
+
+	c, err := OpenCheckpoint(workDir, "repo", "sync", "https://github.com/mona-emu/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Record(oids)
+	c.Close()
+
+	c, err = OpenCheckpoint(workDir, "repo", "sync", "https://github.example.com/mona-emu/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Len() != 0 {
+		t.Errorf("checkpoint of another target kept %d objects", c.Len())
+	}
+
+	data, _ := os.ReadFile(checkpointPath(workDir, "repo", "sync"))
+	if string(data) != "target https://github.example.com/mona-emu/repo.git\n" {
+		t.Errorf("checkpoint not rewritten for the new target: %q", data)
+	}
+}
+
+func TestCheckpointCorruption(t *testing.T) {
+	workDir := t.TempDir()
+	target := "https://github.com/mona-emu/repo.git"
+	oids := testOIDs(2)
+	path := checkpointPath(workDir, "repo", "sync")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"empty", "", 0},
+		{"header only", "target " + target + "\n", 0},
+		{"truncated header", "target https://github", 0},
+		{"garbage lines", "target " + target + "\n" + oids[0] + "\nnot an oid\n" + strings.Repeat("A", 64) + "\n\n", 1},
+		{"truncated last line", "target " + target + "\n" + oids[0] + "\n" + oids[1][:40], 1},
+		{"commit IDs", "target " + target + "\n" + oids[0] + "\n" + oids[1][:40] + "\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := OpenCheckpoint(workDir, "repo", "sync", target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if c.Len() != tt.want {
+				t.Errorf("Len() = %d, want %d", c.Len(), tt.want)
+			}
+
+			// The rewritten file is read back the same
+			if err := c.Record(oids[1:]); err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := OpenCheckpoint(workDir, "repo", "sync", target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if len(reopened.Pending(oids[1:])) != 0 {
+				t.Error("recorded object lost after rewriting the checkpoint")
+			}
+		})
+	}
+}
+
+func TestCheckpointRemove(t *testing.T) {
+	workDir := t.TempDir()
+	c, err := OpenCheckpoint(workDir, "repo", "pull", "https://github.com/mona/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checkpointPath(workDir, "repo", "pull")); !os.IsNotExist(err) {
+		t.Error("checkpoint left after Remove()")
+	}
+	if err := RemoveCheckpoint(workDir, "repo", "pull"); err != nil {
+		t.Errorf("RemoveCheckpoint() of a missing checkpoint = %v", err)
+	}
+}
+
+func TestChunkObjects(t *testing.T) {
+	repoPath := t.TempDir()
+	oids := testOIDs(5)
+	for i, oid := range oids {
+		path := objectPath(LFSObjectsDir(repoPath), oid)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, (i+1)*10), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks := ChunkObjects(repoPath, oids, 2, 1000)
+	if !reflect.DeepEqual(chunks, [][]string{oids[0:2], oids[2:4], oids[4:]}) {
+		t.Errorf("chunks by count = %v", chunks)
+	}
+
+	// 10+20+30 bytes reach the limit, then 40+50
+	chunks = ChunkObjects(repoPath, oids, 100, 60)
+	if !reflect.DeepEqual(chunks, [][]string{oids[0:3], oids[3:]}) {
+		t.Errorf("chunks by size = %v", chunks)
+	}
+}
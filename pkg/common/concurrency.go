@@ -0,0 +1,205 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// adaptiveWindow is how often the throughput is measured to decide
+	// whether to add a worker
+	adaptiveWindow = time.Minute
+	// adaptiveCooldown ignores further throttling right after a backoff, as
+	// requests already in flight will still report it
+	adaptiveCooldown = 30 * time.Second
+)
+
+// Concurrency is the number of workers of a pool. In adaptive mode the pool
+// starts with Workers and adjusts the number between Min and Max.
+type Concurrency struct {
+	Workers  int
+	Adaptive bool
+	Min      int
+	Max      int
+}
+
+type concurrencyKey struct{}
+
+// NewConcurrency validates worker settings, using at least one worker
+func NewConcurrency(workers int, adaptive bool, minWorkers, maxWorkers int) (Concurrency, error) {
+	c := Concurrency{Workers: max(workers, 1), Adaptive: adaptive}
+	if !adaptive {
+		return c, nil
+	}
+
+	c.Min = max(minWorkers, 1)
+	c.Max = maxWorkers
+	if c.Max < c.Min {
+		return Concurrency{}, fmt.Errorf("invalid worker range, max-workers %d is lower than min-workers %d", c.Max, c.Min)
+	}
+	c.Workers = min(max(c.Workers, c.Min), c.Max)
+	return c, nil
+}
+
+// String describes the settings for progress output
+func (c Concurrency) String() string {
+	if c.Adaptive {
+		return fmt.Sprintf("%d (adaptive %d-%d)", c.Workers, c.Min, c.Max)
+	}
+	return fmt.Sprint(c.Workers)
+}
+
+// controller limits how many workers of an adaptive pool process jobs at
+// once. It halves the limit when work is throttled or the network fails, and
+// adds a worker each window while the transfer throughput keeps improving.
+type controller struct {
+	min, max int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	active  int // workers holding a slot
+	busy    int // workers holding a slot and processing a job
+	waiting int
+
+	transferred atomic.Int64
+	lastRate    float64
+	increased   bool
+	lastBackoff time.Time
+}
+
+func newController(c Concurrency) *controller {
+	ctl := &controller{min: c.Min, max: c.Max, limit: c.Workers}
+	ctl.cond = sync.NewCond(&ctl.mu)
+	return ctl
+}
+
+func concurrencyFrom(ctx context.Context) *controller {
+	ctl, _ := ctx.Value(concurrencyKey{}).(*controller)
+	return ctl
+}
+
+// acquire waits until the worker may take a job, returning the context's
+// error when it is done first
+func (c *controller) acquire(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.cond.Broadcast()
+	})
+	defer stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting++
+	defer func() { c.waiting-- }()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.active < c.limit {
+			c.active++
+			return nil
+		}
+		c.cond.Wait()
+	}
+}
+
+// start marks a worker holding a slot as processing a job
+func (c *controller) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy++
+}
+
+// release frees the slot of a worker, which processed a job when busy is set
+func (c *controller) release(busy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	if busy {
+		c.busy--
+	}
+	c.cond.Broadcast()
+}
+
+// addTransferred counts bytes transferred by the pool's git commands
+func (c *controller) addTransferred(n int64) {
+	if c != nil && n > 0 {
+		c.transferred.Add(n)
+	}
+}
+
+// run adjusts the limit every window until ctx is done
+func (c *controller) run(ctx context.Context) {
+	ticker := time.NewTicker(adaptiveWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.adjust(float64(c.transferred.Swap(0)) / adaptiveWindow.Seconds())
+		}
+	}
+}
+
+func (c *controller) adjust(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lastRate := c.lastRate
+	c.lastRate = rate
+	increased := c.increased
+	c.increased = false
+
+	switch {
+	case time.Since(c.lastBackoff) < adaptiveWindow:
+		// Let the pool settle after a backoff before measuring again
+	case increased && rate < lastRate*0.9 && c.limit > c.min:
+		c.setLimit(c.limit-1, "throughput dropped after adding a worker", rate)
+	case rate > lastRate*1.05 && c.limit < c.max && c.waiting > 0 && c.busy >= c.limit:
+		c.setLimit(c.limit+1, "throughput improving", rate)
+		c.increased = true
+	}
+}
+
+// backoff halves the limit, at most once per cooldown
+func (c *controller) backoff(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.lastBackoff) < adaptiveCooldown {
+		return
+	}
+	c.lastBackoff = time.Now()
+	c.increased = false
+	if c.limit > c.min {
+		c.setLimit(max(c.limit/2, c.min), reason, c.lastRate)
+	}
+}
+
+func (c *controller) setLimit(limit int, reason string, rate float64) {
+	slog.Info("adjusting workers", "from", c.limit, "to", limit, "reason", reason,
+		"throughput", FormatBytes(int64(rate))+"/s")
+	c.limit = limit
+	c.cond.Broadcast()
+}
+
+// ObserveError lets an adaptive pool in ctx back off when err shows that
+// requests are being throttled or the network is failing. It does nothing for
+// other errors or outside an adaptive pool.
+func ObserveError(ctx context.Context, err error) {
+	ctl := concurrencyFrom(ctx)
+	if ctl == nil || err == nil {
+		return
+	}
+	switch category := ClassifyError(err); category {
+	case CategoryRateLimit, CategoryNetwork:
+		ctl.backoff(category)
+	}
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewConcurrency(t *testing.T) {
+	c, err := NewConcurrency(0, false, 0, 0)
+	if err != nil || c.Workers != 1 {
+		t.Errorf("NewConcurrency(0) = %+v, %v, want one worker", c, err)
+	}
+
+	c, err = NewConcurrency(10, true, 2, 4)
+	if err != nil || c != (Concurrency{Workers: 4, Adaptive: true, Min: 2, Max: 4}) {
+		t.Errorf("NewConcurrency(10, 2-4) = %+v, %v, want 4 workers", c, err)
+	}
+
+	if _, err := NewConcurrency(1, true, 4, 2); err == nil {
+		t.Error("NewConcurrency() accepted max-workers below min-workers")
+	}
+}
+
+func TestControllerAcquireContext(t *testing.T) {
+	ctl := newController(Concurrency{Workers: 1, Adaptive: true, Min: 1, Max: 2})
+	if err := ctl.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ctl.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire() without a free slot = %v, want the context error", err)
+	}
+
+	ctl.release(false)
+	if err := ctl.acquire(context.Background()); err != nil {
+		t.Errorf("acquire() after release = %v", err)
+	}
+}
+
+func TestRunWorkersAdaptiveKeepsOrder(t *testing.T) {
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range 20 {
+			jobs <- i
+		}
+	}()
+
+	var mu sync.Mutex
+	var started []int
+	workers := Concurrency{Workers: 1, Adaptive: true, Min: 1, Max: 4}
+	err := RunWorkers(context.Background(), jobs, workers, NewProcessStats(), func(ctx context.Context, job int) error {
+		mu.Lock()
+		started = append(started, job)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With a limit of one worker jobs run one at a time in the order sent
+	if !slices.IsSorted(started) || len(started) != 20 {
+		t.Errorf("jobs started in order %v", started)
+	}
+}
+
+func TestRunWorkersDraining(t *testing.T) {
+	drainCtx, drain := context.WithCancel(context.Background())
+	ctx := context.WithValue(context.Background(), drainKey{}, drainCtx)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range 5 {
+			jobs <- i
+		}
+	}()
+
+	stats := NewProcessStats()
+	workers := Concurrency{Workers: 1, Adaptive: true, Min: 1, Max: 2}
+	err := RunWorkers(ctx, jobs, workers, stats, func(ctx context.Context, job int) error {
+		drain()
+		return nil
+	})
+	if err == nil {
+		t.Error("RunWorkers() did not report the jobs left")
+	}
+	if stats.Processed != 1 || stats.NotStarted != 4 {
+		t.Errorf("processed %d, not started %d, want 1 and 4", stats.Processed, stats.NotStarted)
+	}
+}
+
+func TestObserveErrorBacksOff(t *testing.T) {
+	ctl := newController(Concurrency{Workers: 4, Adaptive: true, Min: 1, Max: 4})
+	ctx := context.WithValue(context.Background(), concurrencyKey{}, ctl)
+
+	ObserveError(ctx, errors.New("batch response: 403 Forbidden"))
+	if ctl.limit != 4 {
+		t.Errorf("limit = %d after an auth error, want 4", ctl.limit)
+	}
+
+	ObserveError(ctx, errors.New("remote: You have exceeded a secondary rate limit.\nfatal: The requested URL returned error: 403"))
+	if ctl.limit != 2 {
+		t.Errorf("limit = %d after a secondary rate limit, want 2", ctl.limit)
+	}
+}
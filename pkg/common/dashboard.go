@@ -0,0 +1,351 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/logger"
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+const (
+	dashboardRefresh = 500 * time.Millisecond
+	// Without a terminal progress is printed as plain lines at this interval
+	plainRefresh = 30 * time.Second
+	barWidth     = 30
+)
+
+type dashboardKey struct{}
+type taskKey struct{}
+
+// Dashboard shows the overall progress of a run and one line per repository
+// being processed. When stdout is not a terminal it prints plain progress
+// lines at a fixed interval instead.
+type Dashboard struct {
+	title string
+	start time.Time
+
+	mu    sync.Mutex
+	total int
+	done  int
+	tasks []*Task
+
+	area    *pterm.AreaPrinter
+	areaMu  sync.Mutex // serializes drawing the area and printing logs above it
+	restore func()     // restores the log output replaced while the area is shown
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Task is the progress of one repository phase shown on the dashboard
+type Task struct {
+	dashboard *Dashboard
+	repo      string
+	phase     string
+	start     time.Time
+
+	mu           sync.Mutex
+	step         string
+	objectsTotal int   // expected objects, 0 when unknown
+	bytesTotal   int64 // expected bytes, 0 when unknown
+	finished     transfer
+	running      transfer
+}
+
+// transfer is the progress of one or more git-lfs transfers
+type transfer struct {
+	objectsDone  int
+	objectsTotal int
+	bytesDone    int64
+}
+
+// StartDashboard starts showing progress for total repositories
+func StartDashboard(title string, total int) *Dashboard {
+	d := &Dashboard{
+		title: title,
+		start: time.Now(),
+		total: total,
+		stop:  make(chan struct{}),
+	}
+
+	refresh := plainRefresh
+	if IsTerminal() {
+		d.area, _ = pterm.DefaultArea.Start()
+		refresh = dashboardRefresh
+		// Log lines written to the terminal in between would be drawn over
+		if term.IsTerminal(int(os.Stderr.Fd())) {
+			d.restore = logger.SetConsole(logWriter{d})
+		}
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.render()
+			}
+		}
+	}()
+
+	return d
+}
+
+// Stop renders the final state and stops refreshing
+func (d *Dashboard) Stop() {
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	d.wg.Wait()
+
+	d.render()
+	if d.area != nil {
+		if d.restore != nil {
+			d.restore()
+		}
+		d.area.Stop()
+	}
+}
+
+// logWriter prints log lines above the dashboard area: the area is cleared,
+// the lines are written where it started, and it is drawn again below them
+type logWriter struct {
+	d *Dashboard
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.d.areaMu.Lock()
+	defer w.d.areaMu.Unlock()
+	content := w.d.area.GetContent()
+	w.d.area.Update("")
+	n, err := os.Stderr.Write(p)
+	w.d.area.Update(content)
+	return n, err
+}
+
+// AddTotal changes the number of repositories expected, for runs where it is
+// only known as work is found
+func (d *Dashboard) AddTotal(n int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.total += n
+}
+
+// complete counts a finished repository towards the overall progress
+func (d *Dashboard) complete() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done++
+}
+
+// WithDashboard returns a context carrying the dashboard, so that workers
+// and git commands can report to it
+func WithDashboard(ctx context.Context, d *Dashboard) context.Context {
+	return context.WithValue(ctx, dashboardKey{}, d)
+}
+
+func dashboardFrom(ctx context.Context) *Dashboard {
+	d, _ := ctx.Value(dashboardKey{}).(*Dashboard)
+	return d
+}
+
+// StartTask adds a line for a repository phase to the dashboard in ctx. The
+// returned context makes git commands report their progress to the task.
+// Without a dashboard the task does nothing.
+func StartTask(ctx context.Context, repo, phase string) (context.Context, *Task) {
+	d := dashboardFrom(ctx)
+	if d == nil {
+		return ctx, nil
+	}
+
+	t := &Task{dashboard: d, repo: repo, phase: phase, start: time.Now()}
+	d.mu.Lock()
+	d.tasks = append(d.tasks, t)
+	d.mu.Unlock()
+
+	return context.WithValue(ctx, taskKey{}, t), t
+}
+
+// TaskFrom returns the task of a repository phase in ctx, nil when there is
+// no dashboard
+func TaskFrom(ctx context.Context) *Task {
+	t, _ := ctx.Value(taskKey{}).(*Task)
+	return t
+}
+
+// Done removes the task from the dashboard
+func (t *Task) Done() {
+	if t == nil {
+		return
+	}
+	d := t.dashboard
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, task := range d.tasks {
+		if task == t {
+			d.tasks = append(d.tasks[:i], d.tasks[i+1:]...)
+			break
+		}
+	}
+}
+
+// SetStep describes what the task is currently doing
+func (t *Task) SetStep(step string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.step = step
+}
+
+// SetTotals sets the expected number of objects and bytes, when known, and
+// starts counting the transfers towards them from zero
+func (t *Task) SetTotals(objects int, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.objectsTotal = objects
+	t.bytesTotal = bytes
+	t.finished = transfer{}
+	t.running = transfer{}
+}
+
+// setTransfer records the progress of the running transfer, as reported by
+// git-lfs, ignoring commands that transferred nothing
+func (t *Task) setTransfer(objectsDone, objectsTotal int, bytesDone int64) {
+	if t == nil || objectsTotal == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running = transfer{objectsDone: objectsDone, objectsTotal: objectsTotal, bytesDone: bytesDone}
+}
+
+// finishTransfer adds the running transfer to the ones finished, so that the
+// chunks of a repository add up instead of each starting over
+func (t *Task) finishTransfer() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished.objectsDone += t.running.objectsDone
+	t.finished.objectsTotal += t.running.objectsTotal
+	t.finished.bytesDone += t.running.bytesDone
+	t.running = transfer{}
+}
+
+// progress returns the objects transferred, the objects expected and the
+// bytes transferred since the totals were set
+func (t *Task) progress() (int, int, int64) {
+	objectsTotal := t.objectsTotal
+	if objectsTotal == 0 {
+		objectsTotal = t.finished.objectsTotal + t.running.objectsTotal
+	}
+	return t.finished.objectsDone + t.running.objectsDone, objectsTotal, t.finished.bytesDone + t.running.bytesDone
+}
+
+func (t *Task) line(now time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	objectsDone, objectsTotal, bytesDone := t.progress()
+	objects := "-"
+	if objectsTotal > 0 {
+		objects = fmt.Sprintf("%d/%d objects", objectsDone, objectsTotal)
+	}
+
+	bytes := FormatBytes(bytesDone)
+	if t.bytesTotal > 0 {
+		bytes += " / " + FormatBytes(t.bytesTotal)
+	}
+
+	throughput := "-"
+	if elapsed := now.Sub(t.start).Seconds(); bytesDone > 0 && elapsed > 0 {
+		throughput = FormatBytes(int64(float64(bytesDone)/elapsed)) + "/s"
+	}
+
+	return []string{t.repo, t.phase, t.step, objects, bytes, throughput, now.Sub(t.start).Round(time.Second).String()}
+}
+
+func (d *Dashboard) render() {
+	now := time.Now()
+
+	d.mu.Lock()
+	total, done := d.total, d.done
+	tasks := append([]*Task(nil), d.tasks...)
+	d.mu.Unlock()
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].start.Before(tasks[j].start) })
+
+	elapsed := now.Sub(d.start)
+	eta := "-"
+	if done > 0 && total > done {
+		eta = (elapsed / time.Duration(done) * time.Duration(total-done)).Round(time.Second).String()
+	}
+	percent := 0
+	if total > 0 {
+		percent = min(done*100/total, 100)
+	}
+
+	summary := fmt.Sprintf("%s %d/%d (%d%%)  elapsed %s  ETA %s",
+		d.title, done, total, percent, elapsed.Round(time.Second), eta)
+
+	rows := make([][]string, len(tasks))
+	for i, t := range tasks {
+		rows[i] = t.line(now)
+	}
+
+	if d.area == nil {
+		fmt.Println(summary)
+		for _, row := range rows {
+			fmt.Printf("  %s\n", strings.Join(nonEmpty(row), " | "))
+		}
+		return
+	}
+
+	filled := barWidth * percent / 100
+	bar := pterm.FgGreen.Sprint(strings.Repeat("█", filled)) + pterm.FgGray.Sprint(strings.Repeat("░", barWidth-filled))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", bar, summary)
+	if len(rows) > 0 {
+		table, err := pterm.DefaultTable.WithData(rows).Srender()
+		if err == nil {
+			b.WriteString(table)
+		}
+	}
+	d.areaMu.Lock()
+	defer d.areaMu.Unlock()
+	d.area.Update(b.String())
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package common
+
+import "testing"
+
+func TestTaskProgress(t *testing.T) {
+	task := &Task{}
+	check := func(name string, objectsDone, objectsTotal int, bytesDone int64) {
+		t.Helper()
+		gotDone, gotTotal, gotBytes := task.progress()
+		if gotDone != objectsDone || gotTotal != objectsTotal || gotBytes != bytesDone {
+			t.Errorf("%s: progress() = %d, %d, %d, want %d, %d, %d", name, gotDone, gotTotal, gotBytes, objectsDone, objectsTotal, bytesDone)
+		}
+	}
+
+	// Without totals, each chunk adds the objects git-lfs reports
+	task.setTransfer(2, 2, 200)
+	task.finishTransfer()
+	task.setTransfer(1, 3, 50)
+	check("second chunk running", 3, 5, 250)
+	task.finishTransfer()
+	task.setTransfer(0, 0, 0)
+	task.finishTransfer()
+	check("command without transfer", 3, 5, 250)
+
+	// Totals start a new count, which the chunks add up to
+	task.SetTotals(10, 1000)
+	check("totals set", 0, 10, 0)
+	for range 2 {
+		task.setTransfer(5, 5, 500)
+		task.finishTransfer()
+	}
+	check("all chunks finished", 10, 10, 1000)
+
+	var none *Task
+	none.setTransfer(1, 1, 1)
+	none.finishTransfer()
+	none.SetTotals(1, 1)
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Error categories used in failures files and reports
+const (
+	CategoryAuth      = "auth"
+	CategoryNotFound  = "not_found"
+	CategoryRateLimit = "rate_limit"
+	CategoryNetwork   = "network"
+	CategoryArchived  = "archived"
+	CategoryLFS       = "lfs"
+	CategoryGit       = "git"
+	CategoryCanceled  = "canceled"
+	CategoryTimeout   = "timeout"
+	CategoryOversized = "oversized"
+	CategoryUnknown   = "unknown"
+)
+
+// ClassifyError returns the category of an error returned while processing a repository
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		return CategoryTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, ErrOversized) {
+		return CategoryOversized
+	}
+
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return CategoryRateLimit
+	}
+
+	// GitHub answers git and LFS requests over a secondary rate limit with
+	// 403 Forbidden, so the limit text decides before the status does
+	msg := strings.ToLower(err.Error())
+	if containsAny(msg, "rate limit", "secondary rate", "too many requests", "abuse detection") {
+		return CategoryRateLimit
+	}
+
+	var responseErr *github.ErrorResponse
+	if errors.As(err, &responseErr) && responseErr.Response != nil {
+		switch responseErr.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return CategoryAuth
+		case http.StatusNotFound:
+			return CategoryNotFound
+		case http.StatusTooManyRequests:
+			return CategoryRateLimit
+		}
+	}
+
+	switch {
+	case containsAny(msg, "is archived", "archived so it is read-only"):
+		return CategoryArchived
+	case containsAny(msg, "authentication failed", "permission denied", "401 unauthorized", "403 forbidden",
+		"bad credentials", "could not read username", "terminal prompts disabled"):
+		return CategoryAuth
+	case containsAny(msg, "repository not found", "404 not found", "http 404", "status 404", "[404]"):
+		return CategoryNotFound
+	case containsAny(msg, "could not resolve host", "connection refused", "connection reset", "connection timed out",
+		"tls handshake", "i/o timeout", "broken pipe", "unexpected eof", "early eof", "rpc failed", "proxy"):
+		return CategoryNetwork
+	case containsAny(msg, "git-lfs", "git lfs", "lfs content", "lfs object"):
+		return CategoryLFS
+	case hasMessagePrefix(msg, "fatal:", "error:") || strings.Contains(msg, "exit status 128"):
+		return CategoryGit
+	}
+
+	return CategoryUnknown
+}
+
+// hasMessagePrefix reports whether a line of msg, or a message wrapped in it
+// after a colon, starts with one of the prefixes, such as git's "fatal:"
+func hasMessagePrefix(msg string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(msg, prefix) || strings.Contains(msg, "\n"+prefix) || strings.Contains(msg, ": "+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+const testOID = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestClassifyError(t *testing.T) {
+	response := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code, Request: &http.Request{}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"timeout", fmt.Errorf("push: %w", ErrTimeout), CategoryTimeout},
+		{"canceled", fmt.Errorf("clone: %w", context.Canceled), CategoryCanceled},
+		{"oversized", fmt.Errorf("%w: 1 above 2 GB", ErrOversized), CategoryOversized},
+		{"api 401", response(http.StatusUnauthorized), CategoryAuth},
+		{"api 404", fmt.Errorf("failed to check target repository: %w", response(http.StatusNotFound)), CategoryNotFound},
+		{"api 429", response(http.StatusTooManyRequests), CategoryRateLimit},
+		{"api rate limit", &github.RateLimitError{Response: &http.Response{Request: &http.Request{}}}, CategoryRateLimit},
+		{"rate limit text", errors.New("API rate limit exceeded for user"), CategoryRateLimit},
+		{"api 403 secondary limit", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden, Request: &http.Request{}}, Message: "You have exceeded a secondary rate limit"}, CategoryRateLimit},
+		{"git 403 secondary limit", errors.New("remote: You have exceeded a secondary rate limit.\nfatal: unable to access 'https://github.com/mona/repo.git/': The requested URL returned error: 403"), CategoryRateLimit},
+		{"lfs 403 secondary limit", errors.New("batch response: 403 Forbidden: You have exceeded a secondary rate limit"), CategoryRateLimit},
+		{"403 forbidden", errors.New("batch response: 403 Forbidden"), CategoryAuth},
+		{"api 403", response(http.StatusForbidden), CategoryAuth},
+		{"archived", errors.New("ERROR: This repository was archived so it is read-only."), CategoryArchived},
+		{"bad credentials", errors.New("401 Bad credentials"), CategoryAuth},
+		{"terminal prompts", errors.New("fatal: could not read Username for 'https://github.com': terminal prompts disabled"), CategoryAuth},
+		{"repository not found", errors.New("❌ Failed to clone repository: remote: Repository not found.\nfatal: repository 'https://github.com/mona/missing.git/' not found, exit status 128"), CategoryNotFound},
+		{"http 404", errors.New("batch response: HTTP 404"), CategoryNotFound},
+		{"lfs 404", errors.New("[" + testOID + "] Object does not exist on the server: [404] Object does not exist on the server"), CategoryNotFound},
+		{"network", errors.New("fatal: unable to access 'https://github.com/mona/repo.git/': Could not resolve host: github.com"), CategoryNetwork},
+		{"early eof", errors.New("fetch-pack: unexpected disconnect while reading sideband packet\nfatal: early EOF"), CategoryNetwork},
+		{"lfs", errors.New("failed to push LFS content: batch request failed, exit status 2"), CategoryLFS},
+		{"git fatal", errors.New("failed to get default branch: fatal: ref refs/remotes/origin/HEAD is not a symbolic ref"), CategoryGit},
+		{"git exit status", errors.New("failed to set remote url: exit status 128"), CategoryGit},
+
+		// Substrings of OIDs, SHAs and URLs do not decide the category
+		{"404 in oid", errors.New("failed to read object 40404040" + testOID[8:]), CategoryUnknown},
+		{"404 in sha", errors.New("unexpected commit 9404a1b2c3 in checkpoint"), CategoryUnknown},
+		{"not found text", errors.New("checkpoint entry not found in index"), CategoryUnknown},
+		{"github url", errors.New("unexpected response from https://github.com/mona/repo"), CategoryUnknown},
+		{"error in text", errors.New("read error on file"), CategoryUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lfsPointerMaxSize is the size below which git-lfs considers a blob to be a
+// possible pointer file
+const lfsPointerMaxSize = 1024
+
+var sizePattern = regexp.MustCompile(`^(\d+)([kmg]?)$`)
+
+// ParseSize parses a size in bytes with an optional k, m or g suffix, the
+// units git uses
+func ParseSize(value string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("%q is not a size such as 512k or 1m", value)
+	}
+	size, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size: %w", value, err)
+	}
+	switch match[2] {
+	case "k":
+		size <<= 10
+	case "m":
+		size <<= 20
+	case "g":
+		size <<= 30
+	}
+	return size, nil
+}
+
+// BlobFilter returns the git clone argument that leaves out blobs of limit
+// bytes or more, such as "--filter=blob:limit=1m", or "" when limit is empty.
+// The limit uses git's size units and must keep every LFS pointer file.
+func BlobFilter(limit string) (string, error) {
+	limit = strings.ToLower(strings.TrimSpace(limit))
+	if limit == "" {
+		return "", nil
+	}
+
+	size, err := ParseSize(limit)
+	if err != nil {
+		return "", fmt.Errorf("invalid blob-limit: %w", err)
+	}
+	if size < lfsPointerMaxSize {
+		return "", fmt.Errorf("blob-limit %q would leave out LFS pointer files, use at least 1k", limit)
+	}
+
+	return "--filter=blob:limit=" + limit, nil
+}
@@ -0,0 +1,245 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ModeName returns the name of the migration mode for summaries and reports
+func ModeName(branchMode bool) string {
+	if branchMode {
+		return "branch"
+	}
+	return "mirror"
+}
+
+// ListRefs returns every ref in the repository mapped to the SHA it points to
+func ListRefs(git *GitCommand) (map[string]string, error) {
+	output, err := git.Output("for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	refs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		sha, ref, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		refs[ref] = sha
+	}
+
+	return refs, nil
+}
+
+// LFSObjectsDir returns the LFS object directory of a bare or non-bare clone
+func LFSObjectsDir(repoPath string) string {
+	if info, err := os.Stat(filepath.Join(repoPath, ".git")); err == nil && info.IsDir() {
+		return filepath.Join(repoPath, ".git", "lfs", "objects")
+	}
+	return filepath.Join(repoPath, "lfs", "objects")
+}
+
+// objectPath returns where git-lfs keeps an object below an objects directory
+func objectPath(objectsDir, oid string) string {
+	return filepath.Join(objectsDir, oid[0:2], oid[2:4], oid)
+}
+
+// isOID reports whether s is a SHA-256 LFS object ID
+func isOID(s string) bool {
+	return len(s) == 64 && isHex(s)
+}
+
+// isHex reports whether s only holds lowercase hexadecimal digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// LFSPointer is an LFS pointer file in the history of a repository
+type LFSPointer struct {
+	OID  string
+	Path string
+	Size int64  // size of the object, as recorded in the pointer
+	Blob string // ID of the pointer blob
+}
+
+// pointerMaxSize is the largest blob git-lfs reads as a pointer
+const pointerMaxSize = 1024
+
+// pointerBlobs lists the blobs small enough to be LFS pointers in the history
+// of the given refs, or of all refs when none are given, with the path of each
+// blob. Refs are passed on standard input so that any number fits.
+func pointerBlobs(git *GitCommand, refs []string) ([]string, map[string]string, error) {
+	args := []string{"rev-list", "--objects", "--missing=allow-promisor",
+		fmt.Sprintf("--filter=blob:limit=%d", pointerMaxSize+1), "--filter=object:type=blob"}
+	var input []byte
+	if len(refs) == 0 {
+		args = append(args, "--all")
+	} else {
+		args = append(args, "--stdin")
+		input = []byte(strings.Join(refs, "\n") + "\n")
+	}
+	output, err := git.InputOutput(input, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Lines read "<blob> <path>", commits are listed without a path
+	var blobs []string
+	paths := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		blob, path, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		if _, seen := paths[blob]; !seen {
+			blobs = append(blobs, blob)
+			paths[blob] = path
+		}
+	}
+	return blobs, paths, nil
+}
+
+// ListLFSPointers returns the LFS pointers in the history of the given refs, or
+// of all refs when none are given, whether or not their content is present
+// locally. Like git-lfs, it reads every blob small enough to be a pointer. An
+// object appears once per pointer blob, with one path referencing it.
+func ListLFSPointers(git *GitCommand, refs ...string) ([]LFSPointer, error) {
+	blobs, paths, err := pointerBlobs(git, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LFS objects: %w", err)
+	}
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+
+	output, err := git.InputOutput([]byte(strings.Join(blobs, "\n")+"\n"), "cat-file", "--batch")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LFS pointers: %w", err)
+	}
+
+	// Each blob reads "<blob> <type> <size>\n<content>\n", or "<blob> missing\n"
+	var pointers []LFSPointer
+	for len(output) > 0 {
+		header, rest, _ := bytes.Cut(output, []byte("\n"))
+		output = rest
+		fields := strings.Fields(string(header))
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size > len(output) {
+			return nil, fmt.Errorf("failed to read LFS pointers: unexpected cat-file header %q", header)
+		}
+		content := output[:size]
+		output = bytes.TrimPrefix(output[size:], []byte("\n"))
+
+		if oid, objectSize, ok := parsePointer(content); ok {
+			pointers = append(pointers, LFSPointer{OID: oid, Path: paths[fields[0]], Size: objectSize, Blob: fields[0]})
+		}
+	}
+	return pointers, nil
+}
+
+// pointerPaths maps each object of the pointers to the first path referencing it
+func pointerPaths(pointers []LFSPointer) map[string]string {
+	paths := make(map[string]string)
+	for _, pointer := range pointers {
+		if _, ok := paths[pointer.OID]; !ok {
+			paths[pointer.OID] = pointer.Path
+		}
+	}
+	return paths
+}
+
+// parsePointer reads the OID and size of an LFS pointer file
+func parsePointer(content []byte) (string, int64, bool) {
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "version https://git-lfs.github.com/spec/") &&
+		lines[0] != "version https://hawser.github.com/spec/v1" {
+		return "", 0, false
+	}
+
+	var oid string
+	size := int64(-1)
+	for _, line := range lines[1:] {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			oid, _ = strings.CutPrefix(value, "sha256:")
+		case "size":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+				size = n
+			}
+		}
+	}
+	if !isOID(oid) || size < 0 {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// HasLFSObject reports whether the content of an object is present locally
+func HasLFSObject(repoPath, oid string) bool {
+	_, err := os.Stat(objectPath(LFSObjectsDir(repoPath), oid))
+	return err == nil
+}
+
+// LFSObjectStats counts the LFS objects stored locally for a repository and their total size
+func LFSObjectStats(repoPath string) (int, int64, error) {
+	var objects int
+	var bytes int64
+
+	err := filepath.WalkDir(LFSObjectsDir(repoPath), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read LFS objects: %w", err)
+	}
+
+	return objects, bytes, nil
+}
+
+// FormatBytes renders a byte count in human readable units
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
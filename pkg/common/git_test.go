@@ -0,0 +1,129 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pointerFile returns the content of an LFS pointer file
+func pointerFile(oid string, size int64) string {
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, size)
+}
+
+// lfsRepo creates a repository whose main branch holds files, and whose
+// feature branch adds more files on top
+func lfsRepo(t *testing.T, main, feature map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, output)
+		}
+	}
+	commit := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		run("add", "-A")
+		run("commit", "-q", "-m", "files")
+	}
+
+	run("init", "-q", "-b", "main")
+	commit(main)
+	run("checkout", "-q", "-b", "feature")
+	commit(feature)
+	return dir
+}
+
+func TestListLFSPointers(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	dir := lfsRepo(t,
+		map[string]string{"a.bin": pointerFile(a, 100), "README.md": "# repo\n", "large.txt": strings.Repeat("x", 2000)},
+		map[string]string{"models/b.bin": pointerFile(b, 3<<30), "copy.bin": pointerFile(a, 100)},
+	)
+	git := NewGitCommand(context.Background(), "repo", dir, nil)
+
+	pointers, err := ListLFSPointers(git)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]LFSPointer)
+	for _, pointer := range pointers {
+		if pointer.Blob == "" {
+			t.Errorf("pointer %+v without blob", pointer)
+		}
+		pointer.Blob = ""
+		got[pointer.OID] = pointer
+	}
+	if len(pointers) != 2 || got[a].Size != 100 || got[b] != (LFSPointer{OID: b, Path: "models/b.bin", Size: 3 << 30}) {
+		t.Errorf("ListLFSPointers() = %+v", pointers)
+	}
+
+	pointers, err = ListLFSPointers(git, "refs/heads/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pointers) != 1 || pointers[0].OID != a || pointers[0].Path != "a.bin" {
+		t.Errorf("ListLFSPointers(main) = %+v", pointers)
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	oid := strings.Repeat("c", 64)
+	tests := []struct {
+		content string
+		size    int64
+		ok      bool
+	}{
+		{pointerFile(oid, 42), 42, true},
+		{pointerFile(oid, 0), 0, true},
+		{"version https://hawser.github.com/spec/v1\noid sha256:" + oid + "\nsize 7\n", 7, true},
+		{"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n", 0, false},
+		{"version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 1\n", 0, false},
+		{"oid sha256:" + oid + "\nsize 1\n", 0, false},
+		{"plain text\n", 0, false},
+	}
+	for _, tt := range tests {
+		got, size, ok := parsePointer([]byte(tt.content))
+		if ok != tt.ok || size != tt.size || (ok && got != oid) {
+			t.Errorf("parsePointer(%q) = %q, %d, %v", tt.content, got, size, ok)
+		}
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	dir := lfsRepo(t, map[string]string{"README.md": "# repo\n"}, map[string]string{"NOTES.md": "notes\n"})
+
+	refs, err := ListRefs(NewGitCommand(context.Background(), "repo", dir, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || !isHex(refs["refs/heads/main"]) || !isHex(refs["refs/heads/feature"]) {
+		t.Errorf("ListRefs() = %v", refs)
+	}
+
+	// A cancelled run stops git instead of leaving it running
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ListRefs(NewGitCommand(ctx, "repo", dir, nil)); err == nil {
+		t.Error("ListRefs() with a cancelled context succeeded")
+	}
+}
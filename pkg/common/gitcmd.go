@@ -0,0 +1,272 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/pterm/pterm"
+)
+
+// DebugDirName is the directory in the work dir holding the per-repository
+// git trace logs written in debug mode
+const DebugDirName = "debug"
+
+// How long a canceled git command gets to exit before it is killed
+const gitCancelGrace = 10 * time.Second
+
+var (
+	debugDir string
+	debugMu  sync.Mutex
+
+	// Lines written by GIT_TRACE, GIT_TRACE_PACKET and GIT_CURL_VERBOSE for
+	// git and git-lfs, kept out of the output returned to callers
+	traceLine = regexp.MustCompile(`^(\d{2}:\d{2}:\d{2}\.\d+ |trace git-lfs: |[<>*] |== Info:|=> Send |<= Recv )`)
+)
+
+// EnableGitDebug runs every git command with tracing enabled and appends the
+// redacted output to a log file per repository in workDir/debug
+func EnableGitDebug(workDir string) error {
+	dir := filepath.Join(workDir, DebugDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create debug log directory: %w", err)
+	}
+
+	debugMu.Lock()
+	debugDir = dir
+	debugMu.Unlock()
+
+	pterm.Info.Printf("Debug mode: git trace logs are written to %s\n", dir)
+	return nil
+}
+
+// DebugLogPath returns the git trace log of a repository, or an empty string
+// when debug mode is disabled
+func DebugLogPath(repoName string) string {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	if debugDir == "" {
+		return ""
+	}
+	return filepath.Join(debugDir, repoName+".log")
+}
+
+// GitCommand runs git commands in a repository directory. Commands still
+// running when the context is canceled are terminated.
+type GitCommand struct {
+	Ctx  context.Context
+	Repo string
+	Dir  string
+	Env  []string
+}
+
+// NewGitCommand returns a GitCommand for a repository. A nil env inherits
+// the environment of the current process.
+func NewGitCommand(ctx context.Context, repoName, dir string, env []string) *GitCommand {
+	return &GitCommand{Ctx: ctx, Repo: repoName, Dir: dir, Env: env}
+}
+
+// Run runs git, discarding its output
+func (g *GitCommand) Run(args ...string) error {
+	_, _, err := g.run(nil, args)
+	return err
+}
+
+// Output runs git and returns its standard output
+func (g *GitCommand) Output(args ...string) ([]byte, error) {
+	stdout, _, err := g.run(nil, args)
+	return stdout, err
+}
+
+// InputOutput runs git with input on its standard input and returns its
+// standard output
+func (g *GitCommand) InputOutput(input []byte, args ...string) ([]byte, error) {
+	stdout, _, err := g.run(input, args)
+	return stdout, err
+}
+
+// CombinedOutput runs git and returns its standard output followed by its
+// standard error
+func (g *GitCommand) CombinedOutput(args ...string) ([]byte, error) {
+	stdout, stderr, err := g.run(nil, args)
+	return append(stdout, stderr...), err
+}
+
+func (g *GitCommand) run(input []byte, args []string) ([]byte, []byte, error) {
+	logPath := DebugLogPath(g.Repo)
+
+	env := g.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	if logPath != "" {
+		env = append(env[:len(env):len(env)], "GIT_TRACE=1", "GIT_TRACE_PACKET=1", "GIT_CURL_VERBOSE=1", "GIT_TRANSFER_TRACE=1")
+	}
+	if args[0] == "lfs" {
+		// git-lfs only reads pointer-sized blobs and skips missing ones, so in
+		// a partial clone it must not make git download the large blobs
+		env = append(env[:len(env):len(env)], "GIT_NO_LAZY_FETCH=1")
+	}
+
+	ctx := g.Ctx
+	timeouts := currentGitTimeouts()
+	if timeouts.Operation > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeouts.Operation,
+			fmt.Errorf("%w: git %s took longer than %s", ErrTimeout, args[0], timeouts.Operation))
+		defer cancel()
+	}
+
+	// git-lfs reports transfer progress to GIT_LFS_PROGRESS, which feeds both
+	// the dashboard and stall detection
+	task := TaskFrom(ctx)
+	task.SetStep(gitStep(args))
+	pool := concurrencyFrom(ctx)
+	var progressPath string
+	if task != nil || pool != nil || timeouts.Stall > 0 {
+		if progressFile, err := os.CreateTemp("", "ghmlfs-progress-*"); err == nil {
+			progressFile.Close()
+			progressPath = progressFile.Name()
+			defer os.Remove(progressPath)
+			env = append(env[:len(env):len(env)], "GIT_LFS_PROGRESS="+progressPath)
+		}
+	}
+
+	var written atomic.Int64
+	if timeouts.Stall > 0 {
+		// git itself shows up as growing pack files
+		watched := transferDirs(g.Dir, g.Repo)
+		if progressPath != "" {
+			watched = append(watched, progressPath)
+		}
+
+		var stop context.CancelCauseFunc
+		ctx, stop = context.WithCancelCause(ctx)
+		defer stop(nil)
+		go watchStall(ctx, timeouts.Stall, func() int64 {
+			return written.Load() + filesSize(watched...)
+		}, stop)
+	}
+
+	if (task != nil || pool != nil) && progressPath != "" {
+		progress := newLFSProgress(progressPath)
+		var reported int64
+		update := func() {
+			objectsDone, objectsTotal, bytesDone := progress.poll()
+			task.setTransfer(objectsDone, objectsTotal, bytesDone)
+			pool.addTransferred(bytesDone - reported)
+			reported = max(reported, bytesDone)
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(dashboardRefresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					update()
+					return
+				case <-ticker.C:
+					update()
+				}
+			}
+		}()
+		defer func() {
+			close(done)
+			wg.Wait()
+			task.finishTransfer()
+		}()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.Dir
+	cmd.Env = env
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	cmd.Stdout = progressWriter{&stdout, &written}
+	cmd.Stderr = progressWriter{&stderr, &written}
+	cmd.WaitDelay = gitCancelGrace
+	setProcessGroup(cmd)
+
+	start := time.Now()
+	err := cmd.Run()
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", context.Cause(ctx), err)
+	} else if err != nil {
+		// Throttling and network failures only show in the output
+		ObserveError(ctx, fmt.Errorf("%w: %s", err, stderr.Bytes()))
+	}
+
+	if logPath == "" {
+		return stdout.Bytes(), stderr.Bytes(), err
+	}
+
+	writeDebugLog(logPath, g.Dir, args, stdout.Bytes(), stderr.Bytes(), time.Since(start), err)
+	return stdout.Bytes(), stripTrace(stderr.Bytes()), err
+}
+
+// writeDebugLog appends a redacted record of a git command to the log file
+func writeDebugLog(path, dir string, args []string, stdout, stderr []byte, duration time.Duration, cmdErr error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s git %s\n", time.Now().Format(time.RFC3339), strings.Join(args, " "))
+	fmt.Fprintf(&b, "dir: %s\n", dir)
+	if len(stdout) > 0 {
+		fmt.Fprintf(&b, "--- stdout\n%s\n", bytes.TrimRight(stdout, "\n"))
+	}
+	if len(stderr) > 0 {
+		fmt.Fprintf(&b, "--- stderr\n%s\n", bytes.TrimRight(stderr, "\n"))
+	}
+	status := "ok"
+	if cmdErr != nil {
+		status = cmdErr.Error()
+	}
+	fmt.Fprintf(&b, "--- exit: %s (%s)\n\n", status, duration.Round(time.Millisecond))
+
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(redact.String(b.String()))
+}
+
+// stripTrace removes trace lines from git output
+func stripTrace(output []byte) []byte {
+	var kept [][]byte
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if !traceLine.Match(line) {
+			kept = append(kept, line)
+		}
+	}
+	return bytes.Join(kept, []byte("\n"))
+}
+
+// gitStep names a git command for the dashboard, such as "clone" or "lfs push"
+func gitStep(args []string) string {
+	if len(args) > 1 && args[0] == "lfs" {
+		return "lfs " + args[1]
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GitHubHost returns the host of a GitHub hostname given with or without
+// scheme and API path, such as github.example.com for
+// https://github.example.com/api/v3, and github.com when empty
+func GitHubHost(hostname string) string {
+	host := strings.TrimSpace(hostname)
+	host = strings.TrimPrefix(host, "http://")
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(host, "/api/v3")
+	host = strings.TrimSuffix(host, "/")
+	if host == "" || host == "api.github.com" {
+		return "github.com"
+	}
+	return host
+}
+
+// GitHubAPIURL returns the API URL of a GitHub Enterprise Server hostname,
+// empty for GitHub.com
+func GitHubAPIURL(hostname string) string {
+	if host := GitHubHost(hostname); host != "github.com" {
+		return fmt.Sprintf("https://%s/api/v3", host)
+	}
+	return ""
+}
+
+// RepositoryURL returns the git URL of a repository on a GitHub hostname, or
+// on GitHub.com when the hostname is empty
+func RepositoryURL(hostname, org, repo string) string {
+	return fmt.Sprintf("https://%s/%s/%s.git", GitHubHost(hostname), org, repo)
+}
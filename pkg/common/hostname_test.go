@@ -0,0 +1,26 @@
+package common
+
+import "testing"
+
+func TestGitHubHostnames(t *testing.T) {
+	tests := []struct {
+		hostname string
+		api      string
+		repo     string
+	}{
+		{"", "", "https://github.com/org/repo.git"},
+		{"github.com", "", "https://github.com/org/repo.git"},
+		{"https://api.github.com/", "", "https://github.com/org/repo.git"},
+		{"github.example.com", "https://github.example.com/api/v3", "https://github.example.com/org/repo.git"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/v3", "https://github.example.com/org/repo.git"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/v3", "https://github.example.com/org/repo.git"},
+	}
+	for _, tt := range tests {
+		if got := GitHubAPIURL(tt.hostname); got != tt.api {
+			t.Errorf("GitHubAPIURL(%q) = %q, want %q", tt.hostname, got, tt.api)
+		}
+		if got := RepositoryURL(tt.hostname, "org", "repo"); got != tt.repo {
+			t.Errorf("RepositoryURL(%q) = %q, want %q", tt.hostname, got, tt.repo)
+		}
+	}
+}
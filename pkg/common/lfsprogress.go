@@ -0,0 +1,79 @@
+package common
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lfsProgress follows the file git-lfs writes transfer progress to when
+// GIT_LFS_PROGRESS is set. Each line has the form
+// "<direction> <file>/<total files> <bytes>/<file size> <name>".
+type lfsProgress struct {
+	path    string
+	offset  int64
+	partial string
+
+	files        map[string]int64
+	objectsDone  int
+	objectsTotal int
+}
+
+func newLFSProgress(path string) *lfsProgress {
+	return &lfsProgress{path: path, files: make(map[string]int64)}
+}
+
+// poll reads the lines written since the last call and returns the objects
+// transferred, the objects to transfer and the bytes transferred so far
+func (p *lfsProgress) poll() (int, int, int64) {
+	if f, err := os.Open(p.path); err == nil {
+		if _, err := f.Seek(p.offset, io.SeekStart); err == nil {
+			data, _ := io.ReadAll(f)
+			p.offset += int64(len(data))
+
+			lines := strings.Split(p.partial+string(data), "\n")
+			p.partial = lines[len(lines)-1]
+			for _, line := range lines[:len(lines)-1] {
+				p.parse(line)
+			}
+		}
+		f.Close()
+	}
+
+	var bytes int64
+	for _, n := range p.files {
+		bytes += n
+	}
+	return p.objectsDone, p.objectsTotal, bytes
+}
+
+func (p *lfsProgress) parse(line string) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return
+	}
+
+	current, total, ok := splitCount(fields[1])
+	if !ok {
+		return
+	}
+	transferred, _, ok := splitCount(fields[2])
+	if !ok {
+		return
+	}
+
+	p.objectsDone = max(p.objectsDone, int(current))
+	p.objectsTotal = max(p.objectsTotal, int(total))
+	p.files[fields[3]] = transferred
+}
+
+func splitCount(field string) (int64, int64, bool) {
+	a, b, ok := strings.Cut(field, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	x, err1 := strconv.ParseInt(a, 10, 64)
+	y, err2 := strconv.ParseInt(b, 10, 64)
+	return x, y, err1 == nil && err2 == nil
+}
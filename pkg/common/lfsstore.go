@@ -0,0 +1,128 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LFSStoreDirName is the directory of the shared LFS object store in the
+// working directory
+const LFSStoreDirName = ".lfs-store"
+
+// LFSStore keeps one copy of each LFS object for all repositories of a working
+// directory, addressed by OID like git-lfs' own object directory. Repositories
+// keep their usual object directory, with hardlinks into the store, so that
+// sync and the per-repository statistics work unchanged.
+type LFSStore struct {
+	dir string
+}
+
+// OpenLFSStore creates the shared store in workDir if needed
+func OpenLFSStore(workDir string) (*LFSStore, error) {
+	dir := filepath.Join(workDir, LFSStoreDirName, "objects")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create shared LFS store: %w", err)
+	}
+	return &LFSStore{dir: dir}, nil
+}
+
+// LinkInto links the objects among oids that the store already has into the
+// repository, so that git-lfs does not download them again. It returns the
+// number and size of the objects linked.
+func (s *LFSStore) LinkInto(repoPath string, oids []string) (int, int64, error) {
+	if s == nil {
+		return 0, 0, nil
+	}
+
+	objectsDir := LFSObjectsDir(repoPath)
+	var linked int
+	var size int64
+	for _, oid := range oids {
+		dst := objectPath(objectsDir, oid)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		src := objectPath(s.dir, oid)
+		info, err := os.Stat(src)
+		if err != nil {
+			continue
+		}
+		if err := linkOrCopy(src, dst); err != nil {
+			return linked, size, err
+		}
+		linked++
+		size += info.Size()
+	}
+	return linked, size, nil
+}
+
+// Add links every object of the repository that the store does not have yet
+// into the store, returning how many were added
+func (s *LFSStore) Add(repoPath string) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+
+	var added int
+	err := filepath.WalkDir(LFSObjectsDir(repoPath), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !isOID(d.Name()) {
+			return nil
+		}
+
+		dst := objectPath(s.dir, d.Name())
+		if _, err := os.Stat(dst); err == nil {
+			return nil
+		}
+		if err := linkOrCopy(path, dst); err != nil {
+			return err
+		}
+		added++
+		return nil
+	})
+	if err != nil {
+		return added, fmt.Errorf("failed to add LFS objects to the shared store: %w", err)
+	}
+	return added, nil
+}
+
+// linkOrCopy hardlinks src to dst, copying it when the file system does not
+// support hardlinks between the two paths. dst only appears once complete.
+func linkOrCopy(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil || os.IsExist(err) {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, in)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// MissingObject is an LFS object referenced in a repository without its
+// content: on the source server when pull reports it, usually because its
+// pointer was committed without the content ever being uploaded, or locally
+// when an incomplete push skips it
+type MissingObject struct {
+	OID    string   `json:"oid"`
+	Path   string   `json:"path,omitempty"`
+	Commit string   `json:"commit,omitempty"`
+	Refs   []string `json:"refs,omitempty"`
+}
+
+// git-lfs reports each object it failed to transfer as "[<oid>] <error>"
+var objectErrorPattern = regexp.MustCompile(`^\[([0-9a-f]{64})\] (.*)$`)
+
+// ParseMissingObjects returns the objects that a failed git-lfs transfer
+// reported as not existing on the server. It returns nil when any object
+// failed for another reason, as the failure is then not only about gaps on
+// the server.
+func ParseMissingObjects(output []byte) []string {
+	var oids []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := objectErrorPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		// The batch API answers objects it does not have with a 404 error
+		// naming the object, such as "Object does not exist on the server:
+		// [404] Object does not exist on the server"
+		message := strings.ToLower(match[2])
+		if !strings.Contains(message, "does not exist on the server") && !strings.Contains(message, "[404]") {
+			return nil
+		}
+		oids = append(oids, match[1])
+	}
+	return oids
+}
+
+// DescribeMissingObjects adds a path referencing each object, taken from the
+// pointers of the repository, and the latest commit that changed it there
+func DescribeMissingObjects(git *GitCommand, oids []string, pointers []LFSPointer) []MissingObject {
+	paths := pointerPaths(pointers)
+
+	seen := make(map[string]bool)
+	var missing []MissingObject
+	for _, oid := range oids {
+		if seen[oid] {
+			continue
+		}
+		seen[oid] = true
+
+		object := MissingObject{OID: oid, Path: paths[oid]}
+		// Only the pointers at the path are searched, so that a partial
+		// clone does not download large blobs to find the commit
+		if object.Path != "" {
+			if output, err := git.Output("log", "--all", "-1", "--format=%H", "-S", "oid sha256:"+oid, "--", object.Path); err == nil {
+				object.Commit = strings.TrimSpace(string(output))
+			}
+		}
+		missing = append(missing, object)
+	}
+	return missing
+}
+
+// SaveMissingObjects writes the missing objects of a command run to a manifest
+// in the working directory, one row per object. Nothing is written when no
+// objects were missing.
+func (s *ProcessStats) SaveMissingObjects(workDir, command string) {
+	s.mu.Lock()
+	var rows [][]string
+	for _, result := range s.Results {
+		for _, object := range result.Missing {
+			rows = append(rows, []string{result.Repository.Name, object.OID, object.Path, object.Commit, strings.Join(object.Refs, " ")})
+		}
+	}
+	s.mu.Unlock()
+	if len(rows) == 0 {
+		return
+	}
+
+	filename := filepath.Join(workDir, fmt.Sprintf("%s_missing_objects_%s.csv", command, time.Now().Format("20060102T150405")))
+	if err := writeCSV(filename, []string{"Repository", "OID", "Path", "Commit", "Refs"}, rows); err != nil {
+		pterm.Warning.Printf("Failed to write %s missing objects manifest: %v\n", command, err)
+		return
+	}
+	pterm.Info.Printf("LFS objects missing in %s written to %s\n", command, filename)
+}
+
+func writeCSV(filename string, header []string, rows [][]string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+)
+
+func TestParseMissingObjects(t *testing.T) {
+	oid1 := strings.Repeat("a", 64)
+	oid2 := strings.Repeat("b", 64)
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			"git-lfs 3",
+			"fetch: Fetching all references...\n" +
+				"[" + oid1 + "] Object does not exist on the server: [404] Object does not exist on the server\n" +
+				"[" + oid2 + "] Object does not exist on the server: [404] Object does not exist on the server\n" +
+				"error: failed to fetch some objects from 'https://github.com/mona/repo.git/info/lfs'\n",
+			[]string{oid1, oid2},
+		},
+		{
+			"git-lfs 2",
+			"[" + oid1 + "] Object does not exist on the server or you don't have permissions to access it: [404] Object does not exist on the server or you don't have permissions to access it\n" +
+				"error: failed to fetch some objects from 'https://github.com/mona/repo.git/info/lfs'\n",
+			[]string{oid1},
+		},
+		{
+			"other object error",
+			"[" + oid1 + "] Object does not exist on the server: [404] Object does not exist on the server\n" +
+				"[" + oid2 + "] Post \"https://lfs.github.com/batch\": dial tcp: connection reset by peer\n",
+			nil,
+		},
+		{
+			"not found without 404",
+			"[" + oid1 + "] Error downloading object: model.bin (" + oid1[:7] + "): file not found in the cache\n",
+			nil,
+		},
+		{
+			"repository not found",
+			"batch response: Repository or object not found: https://github.com/mona/repo.git/info/lfs/objects/batch\n" +
+				"Check that it exists and that you have proper access to it\n" +
+				"error: failed to fetch some objects from 'https://github.com/mona/repo.git/info/lfs'\n",
+			nil,
+		},
+		{
+			"404 in another oid",
+			"[" + oid1 + "] Smudge error: expected OID 404" + oid2[3:] + ", got " + oid2 + "\n",
+			nil,
+		},
+		{"no object errors", "fatal: repository not found\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMissingObjects([]byte(tt.output)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMissingObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveMissingObjects(t *testing.T) {
+	workDir := t.TempDir()
+	stats := NewProcessStats()
+	stats.SaveMissingObjects(workDir, "pull")
+	if files, _ := filepath.Glob(filepath.Join(workDir, "pull_missing_objects_*.csv")); len(files) != 0 {
+		t.Fatalf("manifest written without missing objects: %v", files)
+	}
+
+	stats.RecordResult(RepoResult{Repository: inventory.Repository{Name: "repo"}, Missing: []MissingObject{
+		{OID: strings.Repeat("a", 64), Path: "a.bin", Commit: strings.Repeat("c", 40)},
+		{OID: strings.Repeat("b", 64), Path: "b.bin", Refs: []string{"refs/heads/main", "refs/tags/v1"}},
+	}})
+	stats.SaveMissingObjects(workDir, "pull")
+
+	files, _ := filepath.Glob(filepath.Join(workDir, "pull_missing_objects_*.csv"))
+	if len(files) != 1 {
+		t.Fatalf("manifests = %v, want one", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Repository,OID,Path,Commit,Refs\n" +
+		"repo," + strings.Repeat("a", 64) + ",a.bin," + strings.Repeat("c", 40) + ",\n" +
+		"repo," + strings.Repeat("b", 64) + ",b.bin,,refs/heads/main refs/tags/v1\n"
+	if string(data) != want {
+		t.Errorf("manifest = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"errors"
+)
+
+// ErrOversized is returned for a repository with LFS objects larger than the
+// target accepts
+var ErrOversized = errors.New("oversized LFS objects")
+
+// OversizedObject is an LFS object larger than the target accepts
+type OversizedObject struct {
+	OID  string `json:"oid"`
+	Path string `json:"path,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// FindOversizedObjects returns the objects of the pointers larger than limit
+// bytes, once each, with the first path referencing them. Sizes come from the
+// pointers, so objects missing or truncated locally are checked as well.
+func FindOversizedObjects(pointers []LFSPointer, limit int64) []OversizedObject {
+	seen := make(map[string]bool)
+	var oversized []OversizedObject
+	for _, pointer := range pointers {
+		if pointer.Size > limit && !seen[pointer.OID] {
+			seen[pointer.OID] = true
+			oversized = append(oversized, OversizedObject{OID: pointer.OID, Path: pointer.Path, Size: pointer.Size})
+		}
+	}
+	return oversized
+}
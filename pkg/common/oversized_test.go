@@ -0,0 +1,24 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindOversizedObjects(t *testing.T) {
+	small, large := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	pointers := []LFSPointer{
+		{OID: small, Path: "small.bin", Size: 10},
+		{OID: large, Path: "models/large.bin", Size: 11},
+		{OID: large, Path: "copy/large.bin", Size: 11},
+	}
+
+	want := []OversizedObject{{OID: large, Path: "models/large.bin", Size: 11}}
+	if got := FindOversizedObjects(pointers, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindOversizedObjects() = %+v, want %+v", got, want)
+	}
+	if got := FindOversizedObjects(pointers, 11); got != nil {
+		t.Errorf("FindOversizedObjects() under the limit = %+v", got)
+	}
+}
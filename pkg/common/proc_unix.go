@@ -0,0 +1,18 @@
+//go:build !windows
+
+package common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs a command in its own process group, so an interrupt
+// from the terminal only reaches this process, and stops the whole group
+// gracefully when the command's context is canceled
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs a command in its own process group, so an interrupt
+// from the console only reaches this process
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
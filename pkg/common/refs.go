@@ -0,0 +1,146 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefFilter selects the refs whose LFS objects are transferred. Patterns match
+// full source ref names such as refs/heads/main, where * matches any
+// characters including /. A ref is selected when it matches an include
+// pattern, or there are none, matches no exclude pattern and was updated
+// since Since, when set.
+type RefFilter struct {
+	Include []string
+	Exclude []string
+	Since   time.Time
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// ParseRefFilter parses comma separated include and exclude patterns and a
+// date such as 2023-01-31 or an RFC 3339 time
+func ParseRefFilter(include, exclude, since string) (RefFilter, error) {
+	f := RefFilter{Include: splitPatterns(include), Exclude: splitPatterns(exclude)}
+
+	var err error
+	if f.include, err = compilePatterns(f.Include); err != nil {
+		return RefFilter{}, err
+	}
+	if f.exclude, err = compilePatterns(f.Exclude); err != nil {
+		return RefFilter{}, err
+	}
+
+	if since = strings.TrimSpace(since); since != "" {
+		if f.Since, err = time.Parse(time.DateOnly, since); err != nil {
+			if f.Since, err = time.Parse(time.RFC3339, since); err != nil {
+				return RefFilter{}, fmt.Errorf("invalid refs-since %q, use a date such as 2023-01-31", since)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "refs/") {
+			return nil, fmt.Errorf("invalid ref pattern %q, use full ref names such as refs/heads/*", pattern)
+		}
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		compiled = append(compiled, regexp.MustCompile("^"+expr+"$"))
+	}
+	return compiled, nil
+}
+
+// IsZero reports whether the filter selects every ref
+func (f RefFilter) IsZero() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0 && f.Since.IsZero()
+}
+
+// Match reports whether a source ref updated at the given time is selected
+func (f RefFilter) Match(ref string, updated time.Time) bool {
+	if len(f.include) > 0 && !matchAny(f.include, ref) {
+		return false
+	}
+	if matchAny(f.exclude, ref) {
+		return false
+	}
+	return f.Since.IsZero() || !updated.Before(f.Since)
+}
+
+func matchAny(patterns []*regexp.Regexp, ref string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectRefs returns the local refs of a clone that the filter selects. In a
+// mirror clone refs have their source names. In a working clone the source
+// branches are the remote-tracking refs, which are matched as refs/heads/.
+func (f RefFilter) SelectRefs(git *GitCommand) ([]string, error) {
+	output, err := git.Output("for-each-ref", "--format=%(refname) %(creatordate:unix)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	working := false
+	if info, err := os.Stat(filepath.Join(git.Dir, ".git")); err == nil && info.IsDir() {
+		working = true
+	}
+
+	var selected []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		ref, date, _ := strings.Cut(scanner.Text(), " ")
+		source, ok := SourceRef(ref, working)
+		if !ok {
+			continue
+		}
+
+		var updated time.Time
+		if seconds, err := strconv.ParseInt(date, 10, 64); err == nil {
+			updated = time.Unix(seconds, 0)
+		}
+		if f.Match(source, updated) {
+			selected = append(selected, ref)
+		}
+	}
+	return selected, nil
+}
+
+// SourceRef returns the name a local ref has in the source repository. In a
+// working clone only remote-tracking branches and tags come from the source.
+func SourceRef(ref string, working bool) (string, bool) {
+	if !working {
+		return ref, true
+	}
+	if branch, ok := strings.CutPrefix(ref, "refs/remotes/origin/"); ok {
+		return "refs/heads/" + branch, branch != "HEAD"
+	}
+	return ref, strings.HasPrefix(ref, "refs/tags/")
+}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRefFilter(t *testing.T) {
+	f, err := ParseRefFilter(" refs/heads/*, ,refs/tags/v? ", "refs/heads/tmp/*", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Include, []string{"refs/heads/*", "refs/tags/v?"}) || !reflect.DeepEqual(f.Exclude, []string{"refs/heads/tmp/*"}) {
+		t.Errorf("patterns = %q, %q", f.Include, f.Exclude)
+	}
+	if f.IsZero() {
+		t.Error("IsZero() with patterns")
+	}
+
+	if f, err := ParseRefFilter("", "", ""); err != nil || !f.IsZero() {
+		t.Errorf("empty filter = %+v, %v, want zero", f, err)
+	}
+
+	f, err = ParseRefFilter("", "", "2023-01-31")
+	if err != nil || !f.Since.Equal(time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date since = %v, %v", f.Since, err)
+	}
+	f, err = ParseRefFilter("", "", "2023-01-31T12:00:00+02:00")
+	if err != nil || !f.Since.Equal(time.Date(2023, 1, 31, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 since = %v, %v", f.Since, err)
+	}
+
+	for _, args := range [][3]string{
+		{"heads/*", "", ""},
+		{"", "main", ""},
+		{"", "", "31/01/2023"},
+	} {
+		if _, err := ParseRefFilter(args[0], args[1], args[2]); err == nil {
+			t.Errorf("ParseRefFilter(%q) accepted an invalid setting", args)
+		}
+	}
+}
+
+func TestRefFilterMatch(t *testing.T) {
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	before, after := since.Add(-time.Hour), since.Add(time.Hour)
+
+	tests := []struct {
+		include, exclude, since string
+		ref                     string
+		updated                 time.Time
+		want                    bool
+	}{
+		{"", "", "", "refs/heads/main", before, true},
+		{"refs/heads/*", "", "", "refs/heads/main", before, true},
+		// * matches across slashes
+		{"refs/heads/*", "", "", "refs/heads/feature/x", before, true},
+		{"refs/heads/*", "", "", "refs/tags/v1", before, false},
+		{"refs/tags/v?", "", "", "refs/tags/v1", before, true},
+		{"refs/tags/v?", "", "", "refs/tags/v10", before, false},
+		// Patterns match whole names and regexp characters are literal
+		{"refs/heads/main", "", "", "refs/heads/main2", before, false},
+		{"refs/heads/release.1", "", "", "refs/heads/releasex1", before, false},
+		{"", "refs/heads/tmp/*", "", "refs/heads/tmp/a", before, false},
+		{"refs/heads/*", "refs/heads/tmp/*", "", "refs/heads/main", before, true},
+		{"", "", "2023-01-01", "refs/heads/old", before, false},
+		{"", "", "2023-01-01", "refs/heads/new", after, true},
+		{"", "", "2023-01-01", "refs/heads/exact", since, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseRefFilter(tt.include, tt.exclude, tt.since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Match(tt.ref, tt.updated); got != tt.want {
+			t.Errorf("include %q exclude %q since %q: Match(%s, %s) = %v, want %v",
+				tt.include, tt.exclude, tt.since, tt.ref, tt.updated.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
+
+func TestSourceRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		working bool
+		source  string
+		ok      bool
+	}{
+		{"refs/heads/main", false, "refs/heads/main", true},
+		{"refs/pull/1/head", false, "refs/pull/1/head", true},
+		{"refs/remotes/origin/main", true, "refs/heads/main", true},
+		{"refs/remotes/origin/feature/x", true, "refs/heads/feature/x", true},
+		{"refs/remotes/origin/HEAD", true, "refs/heads/HEAD", false},
+		{"refs/tags/v1", true, "refs/tags/v1", true},
+		// Local branches of a working clone are not from the source
+		{"refs/heads/main", true, "refs/heads/main", false},
+		{"refs/remotes/upstream/main", true, "refs/remotes/upstream/main", false},
+	}
+	for _, tt := range tests {
+		source, ok := SourceRef(tt.ref, tt.working)
+		if source != tt.source || ok != tt.ok {
+			t.Errorf("SourceRef(%q, %v) = %q, %v, want %q, %v", tt.ref, tt.working, source, ok, tt.source, tt.ok)
+		}
+	}
+}
+
+// gitRepo creates a repository with commits dated at the given times on the
+// named branches, in order
+func gitRepo(t *testing.T, branches []string, dates []time.Time) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, output)
+		}
+	}
+	run(nil, "init", "-q", "-b", branches[0])
+	for i, branch := range branches {
+		date := "GIT_COMMITTER_DATE=" + dates[i].Format(time.RFC3339)
+		if i > 0 {
+			run(nil, "checkout", "-q", "-b", branch)
+		}
+		run([]string{date}, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", branch)
+	}
+	return dir
+}
+
+func TestSelectRefs(t *testing.T) {
+	old := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	source := gitRepo(t, []string{"main", "feature/x", "tmp/y"}, []time.Time{old, recent, recent})
+	ctx := context.Background()
+
+	clone := func(args ...string) string {
+		t.Helper()
+		dir := filepath.Join(t.TempDir(), "repo")
+		if output, err := exec.Command("git", append(append([]string{"clone", "-q"}, args...), source, dir)...).CombinedOutput(); err != nil {
+			t.Fatalf("git clone: %s", output)
+		}
+		return dir
+	}
+
+	// A mirror clone keeps the source names
+	f, _ := ParseRefFilter("refs/heads/*", "refs/heads/tmp/*", "2023-01-01")
+	selected, err := f.SelectRefs(NewGitCommand(ctx, "repo", clone("--mirror"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []string{"refs/heads/feature/x"}) {
+		t.Errorf("mirror SelectRefs() = %v", selected)
+	}
+
+	// A working clone matches its remote-tracking branches as source branches
+	f, _ = ParseRefFilter("refs/heads/*", "refs/heads/main", "")
+	selected, err = f.SelectRefs(NewGitCommand(ctx, "repo", clone(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []string{"refs/remotes/origin/feature/x", "refs/remotes/origin/tmp/y"}) {
+		t.Errorf("working clone SelectRefs() = %v", selected)
+	}
+}
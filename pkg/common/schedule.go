@@ -0,0 +1,39 @@
+package common
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sort"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+)
+
+// Schedule orders repositories for the worker pool. Repositories with a
+// higher priority come first, then the largest ones. Workers take the next
+// repository as soon as they are free, so starting with the largest keeps a
+// few big repositories from ending up on one worker at the end of the run.
+// Sizes come from the inventory, or from the LFS objects already stored in
+// workDir when the inventory has none.
+func Schedule(repos []inventory.Repository, workDir string) []inventory.Repository {
+	sizes := make(map[string]int64, len(repos))
+	for _, repo := range repos {
+		size := repo.Size
+		if size <= 0 {
+			_, size, _ = LFSObjectStats(filepath.Join(workDir, repo.Name))
+		}
+		sizes[repo.Name] = size
+	}
+
+	scheduled := append([]inventory.Repository(nil), repos...)
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		if scheduled[i].Priority != scheduled[j].Priority {
+			return scheduled[i].Priority > scheduled[j].Priority
+		}
+		return sizes[scheduled[i].Name] > sizes[scheduled[j].Name]
+	})
+
+	for i, repo := range scheduled {
+		slog.Debug("scheduled repository", "repo", repo.Name, "position", i+1, "priority", repo.Priority, "size", FormatBytes(sizes[repo.Name]))
+	}
+	return scheduled
+}
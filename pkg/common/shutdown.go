@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pterm/pterm"
+)
+
+type drainKey struct{}
+
+// NotifyShutdown returns a context that handles SIGINT and SIGTERM in two
+// stages. The first signal cancels the context returned by Draining, so no new
+// repositories are started while the ones in flight finish. The second signal
+// cancels the returned context itself, which stops running git commands.
+func NotifyShutdown(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, abort := context.WithCancel(parent)
+	drainCtx, drain := context.WithCancel(ctx)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			pterm.Warning.Println("Interrupt received, finishing repositories in progress. Interrupt again to abort them.")
+			drain()
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-signals:
+			pterm.Warning.Println("Second interrupt received, aborting repositories in progress")
+			abort()
+		case <-ctx.Done():
+		}
+	}()
+
+	return context.WithValue(ctx, drainKey{}, drainCtx), func() {
+		signal.Stop(signals)
+		abort()
+	}
+}
+
+// Draining returns a context that is canceled once shutdown has started and
+// no new work should be started
+func Draining(ctx context.Context) context.Context {
+	if drainCtx, ok := ctx.Value(drainKey{}).(context.Context); ok {
+		return drainCtx
+	}
+	return ctx
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"os"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+// IsTerminal reports whether stdout is an interactive terminal
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// Spinner is the progress indicator shown while repositories are processed
+type Spinner struct {
+	printer *pterm.SpinnerPrinter
+}
+
+// StartSpinner shows a spinner on stdout. Nothing is shown when stdout is not
+// a terminal, so CI output only contains logs and summaries.
+func StartSpinner(text string) *Spinner {
+	if !IsTerminal() {
+		return &Spinner{}
+	}
+
+	printer, _ := pterm.DefaultSpinner.Start(text)
+	return &Spinner{printer: printer}
+}
+
+// Success stops the spinner
+func (s *Spinner) Success() {
+	if s.printer != nil {
+		s.printer.Success()
+	}
+}
+
+// Fail stops the spinner with a failure mark
+func (s *Spinner) Fail() {
+	if s.printer != nil {
+		s.printer.Fail()
+	}
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTimeout is the cause of work stopped by a timeout or stall
+var ErrTimeout = errors.New("timeout")
+
+// Timeouts bounds the time spent on a repository and on each git command
+type Timeouts struct {
+	// Repository limits all work on one repository, 0 for no limit
+	Repository time.Duration
+	// Operation limits a single git command, 0 for no limit
+	Operation time.Duration
+	// Stall stops a git command whose transfer made no progress for this long, 0 to disable
+	Stall time.Duration
+	// Retries is how many times a repository that timed out is tried again
+	Retries int
+}
+
+var (
+	gitTimeouts   Timeouts
+	gitTimeoutsMu sync.Mutex
+)
+
+// ParseTimeouts parses timeout settings. Empty values disable the timeout, as
+// some git phases such as index-pack write nothing for a long time.
+func ParseTimeouts(repository, operation, stall string, retries int) (Timeouts, error) {
+	t := Timeouts{Retries: max(retries, 0)}
+
+	for _, setting := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"repo-timeout", repository, &t.Repository},
+		{"operation-timeout", operation, &t.Operation},
+		{"stall-timeout", stall, &t.Stall},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(setting.value)
+		if err != nil || d < 0 {
+			return Timeouts{}, fmt.Errorf("invalid %s %q, use a duration such as 30m or 2h", setting.name, setting.value)
+		}
+		*setting.dst = d
+	}
+
+	return t, nil
+}
+
+// SetGitTimeouts applies the operation and stall timeouts to every git command
+func SetGitTimeouts(t Timeouts) {
+	gitTimeoutsMu.Lock()
+	defer gitTimeoutsMu.Unlock()
+	gitTimeouts = t
+}
+
+func currentGitTimeouts() Timeouts {
+	gitTimeoutsMu.Lock()
+	defer gitTimeoutsMu.Unlock()
+	return gitTimeouts
+}
+
+// Run calls fn under the repository timeout, calling it again when it fails
+// with ErrTimeout until the retries are used up
+func (t Timeouts) Run(ctx context.Context, log *slog.Logger, fn func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := t.attempt(ctx, fn)
+		if err == nil || !errors.Is(err, ErrTimeout) || attempt > t.Retries || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("repository timed out, retrying", "attempt", attempt, "error", err)
+	}
+}
+
+func (t Timeouts) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if t.Repository <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, t.Repository,
+		fmt.Errorf("%w: repository took longer than %s", ErrTimeout, t.Repository))
+	defer cancel()
+	return fn(ctx)
+}
+
+// progressWriter counts the bytes a command writes
+type progressWriter struct {
+	dst   io.Writer
+	count *atomic.Int64
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.count.Add(int64(len(p)))
+	return w.dst.Write(p)
+}
+
+// watchStall cancels a command when progress stops changing for limit
+func watchStall(ctx context.Context, limit time.Duration, progress func() int64, stop context.CancelCauseFunc) {
+	interval := min(max(limit/4, time.Second), 30*time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := progress()
+	lastChange := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := progress(); current != last {
+				last = current
+				lastChange = time.Now()
+			} else if time.Since(lastChange) >= limit {
+				stop(fmt.Errorf("%w: no transfer progress for %s", ErrTimeout, limit))
+				return
+			}
+		}
+	}
+}
+
+// transferDirs returns the directories git and git-lfs write incoming data
+// to, for a command run in dir on the repository repoName
+func transferDirs(dir, repoName string) []string {
+	var dirs []string
+	for _, root := range []string{dir, filepath.Join(dir, repoName)} {
+		for _, sub := range []string{"objects/pack", ".git/objects/pack", "lfs/tmp", "lfs/incomplete", ".git/lfs/tmp", ".git/lfs/incomplete"} {
+			dirs = append(dirs, filepath.Join(root, sub))
+		}
+	}
+	return dirs
+}
+
+// filesSize sums the size of the regular files in the given directories and
+// files, ignoring the ones that do not exist
+func filesSize(paths ...string) int64 {
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			total += info.Size()
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	got, err := ParseTimeouts("", "", "", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (Timeouts{}) {
+		t.Errorf("empty settings = %+v, want every timeout off", got)
+	}
+
+	got, err = ParseTimeouts("6h", "2h", "30m", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Timeouts{Repository: 6 * time.Hour, Operation: 2 * time.Hour, Stall: 30 * time.Minute, Retries: 2}
+	if got != want {
+		t.Errorf("ParseTimeouts() = %+v, want %+v", got, want)
+	}
+
+	for _, values := range [][3]string{{"6", "", ""}, {"", "-1m", ""}, {"", "", "soon"}} {
+		if _, err := ParseTimeouts(values[0], values[1], values[2], 0); err == nil {
+			t.Errorf("ParseTimeouts(%q) accepted an invalid duration", values)
+		}
+	}
+}
+
+func TestTimeoutsRunRetries(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name     string
+		retries  int
+		err      error
+		attempts int
+	}{
+		{"success", 2, nil, 1},
+		{"timeout retried", 2, ErrTimeout, 3},
+		{"no retries", 0, ErrTimeout, 1},
+		{"other error not retried", 2, errors.New("push failed"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := Timeouts{Retries: tt.retries}.Run(context.Background(), log, func(context.Context) error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Run() = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestTimeoutsRunRepositoryTimeout(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	attempts := 0
+	err := Timeouts{Repository: 10 * time.Millisecond, Retries: 1}.Run(context.Background(), log, func(ctx context.Context) error {
+		attempts++
+		if attempts == 2 {
+			return nil
+		}
+		<-ctx.Done()
+		return context.Cause(ctx)
+	})
+	if err != nil {
+		t.Errorf("Run() = %v, want the retry to succeed", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestTimeoutsRunCanceled(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	Timeouts{Retries: 3}.Run(ctx, log, func(context.Context) error {
+		attempts++
+		return ErrTimeout
+	})
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want no retry once canceled", attempts)
+	}
+}
+
+func TestWatchStall(t *testing.T) {
+	t.Run("stalled", func(t *testing.T) {
+		t.Parallel()
+		ctx, stop := context.WithCancelCause(context.Background())
+		defer stop(nil)
+
+		go watchStall(ctx, time.Second, func() int64 { return 42 }, stop)
+		select {
+		case <-ctx.Done():
+			if !errors.Is(context.Cause(ctx), ErrTimeout) {
+				t.Errorf("cause = %v, want ErrTimeout", context.Cause(ctx))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("stalled command not stopped")
+		}
+	})
+
+	t.Run("progressing", func(t *testing.T) {
+		t.Parallel()
+		ctx, stop := context.WithCancelCause(context.Background())
+		defer stop(nil)
+
+		var progress atomic.Int64
+		done := make(chan struct{})
+		go func() {
+			watchStall(ctx, time.Second, func() int64 { return progress.Add(1) }, stop)
+			close(done)
+		}()
+
+		time.Sleep(2500 * time.Millisecond)
+		if err := context.Cause(ctx); err != nil {
+			t.Errorf("progressing command stopped: %v", err)
+		}
+		stop(nil)
+		<-done
+	})
+}
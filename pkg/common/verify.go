@@ -0,0 +1,197 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// QuarantineDirName is the directory in the working directory that keeps the
+// LFS objects whose content does not match their OID
+const QuarantineDirName = ".quarantine"
+
+// RefetchDirName is the directory of the re-fetch queues in the working
+// directory, listing the objects of each repository that pull fetches again
+const RefetchDirName = ".refetch"
+
+// CorruptObject is a local LFS object whose content does not match its OID,
+// such as a download truncated by a proxy
+type CorruptObject struct {
+	OID    string `json:"oid"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // hash of the content found
+}
+
+// VerifyResult counts the objects a verification re-hashed and lists the
+// corrupt ones
+type VerifyResult struct {
+	Objects int
+	Bytes   int64
+	Corrupt []CorruptObject
+}
+
+// VerifyLFSObjects re-hashes every local LFS object of a repository with
+// SHA-256. Objects whose content does not match their OID are moved to the
+// quarantine, so that they are neither pushed nor reused, and queued to be
+// fetched again by the next pull.
+func VerifyLFSObjects(ctx context.Context, workDir, repoName string) (VerifyResult, error) {
+	repoPath := filepath.Join(workDir, repoName)
+	task := TaskFrom(ctx)
+	task.SetStep("verify")
+	total, _, _ := LFSObjectStats(repoPath)
+
+	var result VerifyResult
+	err := filepath.WalkDir(LFSObjectsDir(repoPath), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !isOID(d.Name()) {
+			return nil
+		}
+
+		sum, size, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		result.Objects++
+		result.Bytes += size
+		task.setTransfer(result.Objects, total, result.Bytes)
+		if sum != d.Name() {
+			result.Corrupt = append(result.Corrupt, CorruptObject{OID: d.Name(), Size: size, SHA256: sum})
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to verify LFS objects: %w", err)
+	}
+
+	oids := make([]string, 0, len(result.Corrupt))
+	for _, object := range result.Corrupt {
+		if err := quarantine(workDir, repoName, object.OID); err != nil {
+			return result, fmt.Errorf("failed to quarantine LFS object %s: %w", object.OID, err)
+		}
+		oids = append(oids, object.OID)
+	}
+	if len(oids) > 0 {
+		if err := QueueRefetch(workDir, repoName, oids); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// quarantine moves a corrupt object out of the repository, dropping it from
+// the shared store too when the store holds the same file
+func quarantine(workDir, repoName, oid string) error {
+	src := objectPath(LFSObjectsDir(filepath.Join(workDir, repoName)), oid)
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	stored := objectPath(filepath.Join(workDir, LFSStoreDirName, "objects"), oid)
+	if storedInfo, err := os.Stat(stored); err == nil && os.SameFile(info, storedInfo) {
+		if err := os.Remove(stored); err != nil {
+			return err
+		}
+	}
+
+	dst := filepath.Join(workDir, QuarantineDirName, repoName, oid)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func refetchPath(workDir, repoName string) string {
+	return filepath.Join(workDir, RefetchDirName, repoName)
+}
+
+// QueueRefetch adds objects to the re-fetch queue of a repository
+func QueueRefetch(workDir, repoName string, oids []string) error {
+	queued := RefetchQueue(workDir, repoName)
+	for _, oid := range oids {
+		if !slices.Contains(queued, oid) {
+			queued = append(queued, oid)
+		}
+	}
+	return writeRefetchQueue(workDir, repoName, queued)
+}
+
+// RefetchQueue returns the objects queued to be fetched again for a repository
+func RefetchQueue(workDir, repoName string) []string {
+	data, err := os.ReadFile(refetchPath(workDir, repoName))
+	if err != nil {
+		return nil
+	}
+
+	var oids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if isOID(line) {
+			oids = append(oids, line)
+		}
+	}
+	return oids
+}
+
+// PruneRefetchQueue drops the objects present locally again from the re-fetch
+// queue of a repository, returning how many are left
+func PruneRefetchQueue(workDir, repoName string) (int, error) {
+	repoPath := filepath.Join(workDir, repoName)
+
+	var left []string
+	for _, oid := range RefetchQueue(workDir, repoName) {
+		if !HasLFSObject(repoPath, oid) {
+			left = append(left, oid)
+		}
+	}
+	return len(left), writeRefetchQueue(workDir, repoName, left)
+}
+
+// writeRefetchQueue replaces the re-fetch queue of a repository, removing it
+// when empty
+func writeRefetchQueue(workDir, repoName string, oids []string) error {
+	path := refetchPath(workDir, repoName)
+	if len(oids) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove re-fetch queue: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create re-fetch queue directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(oids, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write re-fetch queue: %w", err)
+	}
+	return nil
+}
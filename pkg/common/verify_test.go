@@ -0,0 +1,169 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeObject stores content as an LFS object of a repository under oid,
+// returning the object path
+func writeObject(t *testing.T, repoPath, oid, content string) string {
+	t.Helper()
+	path := objectPath(LFSObjectsDir(repoPath), oid)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyLFSObjects(t *testing.T) {
+	good := sha256Hex("good content")
+	truncated := sha256Hex("truncated content")
+
+	tests := []struct {
+		name        string
+		objects     map[string]string // oid to stored content
+		wantObjects int
+		wantCorrupt []CorruptObject
+	}{
+		{"no objects", nil, 0, nil},
+		{"all valid", map[string]string{good: "good content"}, 1, nil},
+		{
+			"truncated object",
+			map[string]string{good: "good content", truncated: "trunc"},
+			2,
+			[]CorruptObject{{OID: truncated, Size: 5, SHA256: sha256Hex("trunc")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			repoPath := filepath.Join(workDir, "repo")
+			paths := make(map[string]string)
+			for oid, content := range tt.objects {
+				paths[oid] = writeObject(t, repoPath, oid, content)
+			}
+
+			result, err := VerifyLFSObjects(context.Background(), workDir, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Objects != tt.wantObjects || !reflect.DeepEqual(result.Corrupt, tt.wantCorrupt) {
+				t.Errorf("VerifyLFSObjects() = %d objects, corrupt %+v, want %d, %+v", result.Objects, result.Corrupt, tt.wantObjects, tt.wantCorrupt)
+			}
+
+			var queued []string
+			for _, object := range tt.wantCorrupt {
+				queued = append(queued, object.OID)
+				if _, err := os.Stat(paths[object.OID]); !os.IsNotExist(err) {
+					t.Errorf("corrupt object %s left in the repository", object.OID)
+				}
+				if _, err := os.Stat(filepath.Join(workDir, QuarantineDirName, "repo", object.OID)); err != nil {
+					t.Errorf("corrupt object %s not quarantined: %v", object.OID, err)
+				}
+			}
+			if got := RefetchQueue(workDir, "repo"); !reflect.DeepEqual(got, queued) {
+				t.Errorf("RefetchQueue() = %v, want %v", got, queued)
+			}
+			if !HasLFSObject(repoPath, good) && tt.objects[good] != "" {
+				t.Error("valid object removed")
+			}
+		})
+	}
+}
+
+func TestVerifyLFSObjectsSharedStore(t *testing.T) {
+	oid := sha256Hex("content")
+	workDir := t.TempDir()
+	stored := objectPath(filepath.Join(workDir, LFSStoreDirName, "objects"), oid)
+	if err := os.MkdirAll(filepath.Dir(stored), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stored, []byte("cont"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	linked := objectPath(LFSObjectsDir(filepath.Join(workDir, "repo")), oid)
+	if err := os.MkdirAll(filepath.Dir(linked), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(stored, linked); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	result, err := VerifyLFSObjects(context.Background(), workDir, "repo")
+	if err != nil || len(result.Corrupt) != 1 {
+		t.Fatalf("VerifyLFSObjects() = %+v, %v, want one corrupt object", result, err)
+	}
+	if _, err := os.Stat(stored); !os.IsNotExist(err) {
+		t.Error("corrupt object left in the shared store")
+	}
+}
+
+func TestRefetchQueue(t *testing.T) {
+	a, b, c := strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)
+	workDir := t.TempDir()
+	repoPath := filepath.Join(workDir, "repo")
+
+	if got := RefetchQueue(workDir, "repo"); got != nil {
+		t.Errorf("RefetchQueue() without a queue = %v", got)
+	}
+
+	if err := QueueRefetch(workDir, "repo", []string{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := QueueRefetch(workDir, "repo", []string{b, c}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := RefetchQueue(workDir, "repo"), []string{a, b, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RefetchQueue() = %v, want %v", got, want)
+	}
+
+	// Lines that are not OIDs are ignored
+	path := refetchPath(workDir, "repo")
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append(data, []byte("not an oid\n\n")...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fetched []string
+		want    []string
+	}{
+		{"nothing fetched", nil, []string{a, b, c}},
+		{"some fetched", []string{a, c}, []string{b}},
+		{"all fetched", []string{b}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, oid := range tt.fetched {
+				writeObject(t, repoPath, oid, "content")
+			}
+			left, err := PruneRefetchQueue(workDir, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := RefetchQueue(workDir, "repo"); left != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PruneRefetchQueue() = %d, queue %v, want %v", left, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("empty re-fetch queue not removed: %v", err)
+	}
+}
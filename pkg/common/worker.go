@@ -1,18 +1,71 @@
 package common
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
 	"github.com/pterm/pterm"
 )
 
+// ErrSkipped is returned by a process function when a repository needs no work
+var ErrSkipped = errors.New("skipped")
+
 type ProcessStats struct {
-	Processed int32
-	Failed    int32
-	StartTime time.Time
+	Processed  int32
+	Failed     int32
+	Skipped    int32
+	NotStarted int32 // not started because of a shutdown
+	StartTime  time.Time
+	Events     []RepoEvent
+	Results    []RepoResult
+
+	mu sync.Mutex
+}
+
+// RepoEvent records a state change made to a repository during a run
+type RepoEvent struct {
+	Repository string
+	Message    string
+	Time       time.Time
+}
+
+// Repository result statuses
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// RepoResult records the outcome of processing a single repository
+type RepoResult struct {
+	Repository inventory.Repository
+	Phase      string
+	Mode       string
+	Status     string
+	StartTime  time.Time
+	Duration   time.Duration
+	Objects    int
+	Bytes      int64
+	Refs       int
+	Error      string
+	Category   string
+	Missing    []MissingObject   // objects missing on source, or skipped by an incomplete push
+	Oversized  []OversizedObject // objects larger than the target accepts
+	Corrupt    []CorruptObject   // objects quarantined because their content does not match their OID
+}
+
+// SetError marks the result as failed with a classified, redacted error
+func (r *RepoResult) SetError(err error) {
+	r.Status = StatusFailed
+	r.Error = redact.Error(err)
+	r.Category = ClassifyError(err)
 }
 
 func NewProcessStats() *ProcessStats {
@@ -21,49 +74,217 @@ func NewProcessStats() *ProcessStats {
 	}
 }
 
+// RecordEvent adds a repository state change to the run summary
+func (s *ProcessStats) RecordEvent(repository, format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Events = append(s.Events, RepoEvent{
+		Repository: repository,
+		Message:    redact.String(fmt.Sprintf(format, args...)),
+		Time:       time.Now(),
+	})
+}
+
+// RecordResult adds the outcome of processing a repository to the run
+func (s *ProcessStats) RecordResult(result RepoResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Results = append(s.Results, result)
+}
+
+// Failures returns the failed repositories with their error and category
+func (s *ProcessStats) Failures() []inventory.Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var failures []inventory.Repository
+	for _, result := range s.Results {
+		if result.Status == StatusFailed {
+			repo := result.Repository
+			repo.Error = result.Error
+			repo.Category = result.Category
+			failures = append(failures, repo)
+		}
+	}
+	return failures
+}
+
+// WriteFailures writes the failed repositories to a failures file in the
+// inventory format. Nothing is written when there were no failures.
+func (s *ProcessStats) WriteFailures(filename string) error {
+	failures := s.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	return inventory.WriteFailures(filename, failures)
+}
+
+// SaveFailures writes the failures file for a command run to the working directory
+func (s *ProcessStats) SaveFailures(workDir, command string) {
+	filename := filepath.Join(workDir, fmt.Sprintf("%s_failures_%s.csv", command, time.Now().Format("20060102T150405")))
+	if err := s.WriteFailures(filename); err != nil {
+		pterm.Warning.Printf("Failed to write %s failures file: %v\n", command, err)
+		return
+	}
+	if s.Failed > 0 {
+		pterm.Info.Printf("Failed %s repositories written to %s (re-run them with --retry-failed)\n", command, filename)
+	}
+}
+
 func (s *ProcessStats) PrintSummary(workDir string) {
 	fmt.Printf("\n📊 Summary:\n")
 	fmt.Printf("✅ Successfully processed: %d repositories\n", s.Processed)
 	fmt.Printf("❌ Failed: %d repositories\n", s.Failed)
+	if s.Skipped > 0 {
+		fmt.Printf("⏭️  Skipped (already completed): %d repositories\n", s.Skipped)
+	}
+	if s.NotStarted > 0 {
+		fmt.Printf("⏹️  Not started (interrupted): %d repositories\n", s.NotStarted)
+	}
+	if missing, repos := s.objectCounts(func(r RepoResult) int { return len(r.Missing) }); missing > 0 {
+		fmt.Printf("⚠️  LFS objects missing: %d in %d repositories (listed in the report)\n", missing, repos)
+	}
+	if oversized, repos := s.objectCounts(func(r RepoResult) int { return len(r.Oversized) }); oversized > 0 {
+		fmt.Printf("⚠️  Oversized LFS objects: %d in %d repositories (listed in the report)\n", oversized, repos)
+	}
+	if corrupt, repos := s.objectCounts(func(r RepoResult) int { return len(r.Corrupt) }); corrupt > 0 {
+		fmt.Printf("⚠️  Corrupt LFS objects quarantined: %d in %d repositories (pull again to fetch them)\n", corrupt, repos)
+	}
 	if workDir != "" {
 		fmt.Printf("📁 Output directory: %s\n", workDir)
 	}
 	fmt.Printf("🕐 Total time: %v\n", time.Since(s.StartTime).Round(time.Second))
+
+	if len(s.Events) > 0 {
+		fmt.Printf("\n📝 Repository changes:\n")
+		for _, event := range s.Events {
+			fmt.Printf("%s %s: %s\n", event.Time.Format(time.RFC3339), event.Repository, event.Message)
+		}
+	}
 }
 
-// WorkerPool manages a pool of workers processing repository operations
+// objectCounts totals the objects that count returns for each result and the
+// number of repositories with any
+func (s *ProcessStats) objectCounts(count func(RepoResult) int) (int, int) {
+	objects, repos := 0, 0
+	for _, result := range s.Results {
+		if n := count(result); n > 0 {
+			objects += n
+			repos++
+		}
+	}
+	return objects, repos
+}
+
+// WorkerPool manages a pool of workers processing repository operations,
+// showing their progress on a dashboard
 func WorkerPool[T any](
+	ctx context.Context,
 	jobs chan T,
-	maxWorkers int,
+	total int,
+	workers Concurrency,
 	stats *ProcessStats,
-	processFunc func(T) error,
+	processFunc func(context.Context, T) error,
+) error {
+	dashboard := StartDashboard("Processing repositories", total)
+	err := RunWorkers(WithDashboard(ctx, dashboard), jobs, workers, stats, processFunc)
+	dashboard.Stop()
+
+	return err
+}
+
+// RunWorkers processes jobs like WorkerPool but reports to the dashboard in
+// ctx, if any, so that several pools can share one. Once the context returned
+// by Draining is canceled, remaining jobs are received but not started.
+// An adaptive pool starts a goroutine per possible worker and lets only as
+// many take jobs as its current limit allows. Workers take a slot before a
+// job, so that jobs still start in the order they are sent.
+func RunWorkers[T any](
+	ctx context.Context,
+	jobs chan T,
+	workers Concurrency,
+	stats *ProcessStats,
+	processFunc func(context.Context, T) error,
 ) error {
 	var wg sync.WaitGroup
-	spinner, _ := pterm.DefaultSpinner.Start("Processing repositories...")
+	draining := Draining(ctx)
+	dashboard := dashboardFrom(ctx)
+
+	goroutines := workers.Workers
+	var ctl *controller
+	if workers.Adaptive {
+		goroutines = workers.Max
+		ctl = newController(workers)
+		ctx = context.WithValue(ctx, concurrencyKey{}, ctl)
+
+		adjustCtx, stopAdjusting := context.WithCancel(ctx)
+		defer stopAdjusting()
+		go ctl.run(adjustCtx)
+	}
 
 	// Start worker pool
-	for i := 0; i < maxWorkers; i++ {
+	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for job := range jobs {
-				if err := processFunc(job); err != nil {
-					fmt.Printf("Error processing: %v\n", err)
+			for {
+				// A shutdown may start while waiting for a slot
+				if ctl != nil && ctl.acquire(draining) != nil {
+					// Keep receiving so that producers are never blocked
+					for range jobs {
+						atomic.AddInt32(&stats.NotStarted, 1)
+					}
+					return
+				}
+
+				job, ok := <-jobs
+				if !ok {
+					if ctl != nil {
+						ctl.release(false)
+					}
+					return
+				}
+				if draining.Err() != nil {
+					if ctl != nil {
+						ctl.release(false)
+					}
+					atomic.AddInt32(&stats.NotStarted, 1)
+					continue
+				}
+
+				// Process functions log their own errors with the repository attached
+				if ctl != nil {
+					ctl.start()
+				}
+				err := processFunc(ctx, job)
+				if ctl != nil {
+					ObserveError(ctx, err)
+					ctl.release(true)
+				}
+				if errors.Is(err, ErrSkipped) {
+					atomic.AddInt32(&stats.Skipped, 1)
+				} else if err != nil {
 					atomic.AddInt32(&stats.Failed, 1)
 				} else {
 					atomic.AddInt32(&stats.Processed, 1)
 				}
+				dashboard.complete()
 			}
 		}()
 	}
 
 	// Wait for all workers to complete
 	wg.Wait()
-	spinner.Success()
 
+	var errs []error
 	if stats.Failed > 0 {
-		return fmt.Errorf("failed to process %d repositories", stats.Failed)
+		errs = append(errs, fmt.Errorf("failed to process %d repositories", stats.Failed))
+	}
+	if stats.NotStarted > 0 {
+		errs = append(errs, fmt.Errorf("interrupted, %d repositories were not started", stats.NotStarted))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -1,26 +1,24 @@
 package export
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/mona-actions/gh-migrate-lfs/internal/api"
-	"github.com/pterm/pterm"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/logger"
 	"github.com/spf13/viper"
 )
 
 // RepoLFSInfo holds information about a repository containing LFS data
-type RepoLFSInfo struct {
-	Name     string
-	Path     string
-	CloneURL string
-}
+type RepoLFSInfo = inventory.Repository
 
-func ExportLFSRepos() error {
+func ExportLFSRepos(ctx context.Context) error {
 	start := time.Now()
-	spinner, _ := pterm.DefaultSpinner.Start("Searching for repositories with LFS content...")
+	spinner := common.StartSpinner("Searching for repositories with LFS content...")
 
 	// Get configuration
 	organization := viper.GetString("GHMLFS_SOURCE_ORGANIZATION")
@@ -36,92 +34,87 @@ func ExportLFSRepos() error {
 		depth = 1 // Default depth if not specified
 	}
 
-	// Fetch repositories
-	pterm.Info.Printf("Fetching repository list for %s...", organization)
-	repos, err := api.GetRepositories(organization, token, hostname)
+	// Stop searching on the first interrupt, a partial inventory is not written
+	result, err := SearchLFSRepos(common.Draining(ctx), organization, token, hostname, depth)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repositories: %w", err)
-	}
-	pterm.Info.Printf("Found %d repositories\n", len(repos))
-
-	// Process repositories and collect LFS information
-	var lfsRepos []RepoLFSInfo
-	var successful, failed, found int
-
-	pterm.Info.Printf("Checking repositories for LFS content (searching up to depth %d)...", depth)
-
-	for _, repo := range repos {
-		pterm.Info.Printf("Searching repository contents: '%s'...\n", repo)
-
-		hasLFS, path, err := api.CheckGitAttributes(organization, repo, token, depth, hostname)
-		if err != nil {
-			pterm.Info.Printf("Warning: Failed to determine LFS status for repo %s: %v", repo, err)
-			failed++
-			continue
-		}
-
-		if hasLFS {
-			cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", organization, repo)
-			if hostname != "" {
-				cloneURL = fmt.Sprintf("%s/%s/%s.git", hostname, organization, repo)
-			}
-
-			lfsRepos = append(lfsRepos, RepoLFSInfo{
-				Name:     repo,
-				Path:     path,
-				CloneURL: cloneURL,
-			})
-			found++
-			pterm.Success.Printf("LFS filter matched for repository '%s' (path: %s)\n", repo, path)
-		}
-
-		successful++
+		return err
 	}
 
 	// Write results to CSV file
 	outputFile := viper.GetString("GHMLFS_SOURCE_ORGANIZATION") + "_lfs.csv"
-	if err := writeToCSV(outputFile, lfsRepos); err != nil {
+	if err := inventory.Write(outputFile, result.Repos); err != nil {
 		return fmt.Errorf("failed to write CSV file: %w", err)
 	}
 	spinner.Success()
 
 	fmt.Printf("\n📊 Export Summary:\n")
-	fmt.Printf("Total repositories found: %d\n", len(repos))
-	fmt.Printf("✅ Successfully processed: %d repositories\n", successful)
-	fmt.Printf("❌ Failed to process: %d repositories\n", failed)
+	fmt.Printf("Total repositories found: %d\n", result.Total)
+	fmt.Printf("✅ Successfully processed: %d repositories\n", result.Successful)
+	fmt.Printf("❌ Failed to process: %d repositories\n", result.Failed)
 	fmt.Printf("🔍 Maximum search depth: %d\n", depth)
-	fmt.Printf("🔍 Repositories with LFS: %d\n", found)
+	fmt.Printf("🔍 Repositories with LFS: %d\n", len(result.Repos))
 	fmt.Printf("📁 Output file: %s\n", outputFile)
 	fmt.Printf("🕐 Total time: %v\n", time.Since(start).Round(time.Second))
 
 	return nil
 }
 
-func writeToCSV(filename string, repos []RepoLFSInfo) error {
-	file, err := os.Create(filename)
+// SearchResult holds the outcome of searching an organization for LFS repositories
+type SearchResult struct {
+	Repos      []RepoLFSInfo
+	Total      int
+	Successful int
+	Failed     int
+}
+
+// SearchLFSRepos checks every repository of an organization for LFS content.
+// The search stops with the context's error when it is canceled.
+func SearchLFSRepos(ctx context.Context, organization, token, hostname string, depth int) (*SearchResult, error) {
+	// Fetch repositories
+	slog.Info("fetching repository list", "organization", organization)
+	repos, err := api.GetRepositories(ctx, organization, token, hostname)
 	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
+		return nil, fmt.Errorf("failed to fetch repositories: %w", err)
 	}
-	defer file.Close()
+	slog.Info("found repositories", "organization", organization, "count", len(repos))
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	// Process repositories and collect LFS information
+	result := &SearchResult{Total: len(repos)}
 
-	// Write header
-	if err := writer.Write([]string{"Repository", "GitAttributesPaths", "CloneURL"}); err != nil {
-		return fmt.Errorf("error writing header: %w", err)
-	}
+	slog.Info("checking repositories for LFS content", "depth", depth)
 
-	// Write data
 	for _, repo := range repos {
-		if err := writer.Write([]string{
-			repo.Name,
-			repo.Path,
-			repo.CloneURL,
-		}); err != nil {
-			return fmt.Errorf("error writing repository data: %w", err)
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("search interrupted after %d of %d repositories: %w", result.Successful+result.Failed, result.Total, err)
+		}
+
+		log := logger.ForRepo(repo, "export")
+		log.Info("searching repository contents")
+
+		hasLFS, path, err := api.CheckGitAttributes(ctx, organization, repo, token, depth, hostname)
+		if err != nil {
+			log.Warn("failed to determine LFS status", "error", err)
+			result.Failed++
+			continue
+		}
+
+		if hasLFS {
+			cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", organization, repo)
+			if hostname != "" {
+				cloneURL = fmt.Sprintf("%s/%s/%s.git", hostname, organization, repo)
+			}
+
+			info := RepoLFSInfo{
+				Name:     repo,
+				Path:     path,
+				CloneURL: cloneURL,
+			}
+			result.Repos = append(result.Repos, info)
+			log.Info("LFS filter matched", "path", path)
 		}
+
+		result.Successful++
 	}
 
-	return nil
+	return result, nil
 }
@@ -0,0 +1,202 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+)
+
+const (
+	columnRepository = "Repository"
+	columnPath       = "GitAttributesPaths"
+	columnCloneURL   = "CloneURL"
+	columnError      = "Error"
+	columnCategory   = "Category"
+	columnSize       = "Size"
+	columnPriority   = "Priority"
+)
+
+// Repository is a row of the LFS repository inventory written by export
+type Repository struct {
+	Name     string
+	Path     string
+	CloneURL string
+
+	// Optional scheduling hints: the LFS size in bytes, and a priority where
+	// higher values are processed first
+	Size     int64
+	Priority int
+
+	// Set for repositories listed in a failures file
+	Error    string
+	Category string
+}
+
+// Read loads an inventory CSV file, skipping duplicate repositories. Columns
+// are matched by header name so files with additional columns, such as
+// failures files, can be read as well. Files without a Repository header
+// column are read by position as repository, path and clone URL, where a
+// first row with a clone URL is data rather than a header.
+func Read(filename string) ([]Repository, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening input file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	var first []string
+	if _, ok := columns[columnRepository]; !ok {
+		columns = map[string]int{columnRepository: 0, columnPath: 1, columnCloneURL: 2}
+		if len(header) > 2 && strings.Contains(header[2], "://") {
+			first = header
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var repos []Repository
+	seen := make(map[string]bool)
+	for {
+		var record []string
+		if first != nil {
+			record, first = first, nil
+		} else if record, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			slog.Warn("error reading CSV record", "file", filename, "error", err)
+			continue
+		}
+
+		name := field(record, columnRepository)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		repo := Repository{
+			Name:     name,
+			Path:     field(record, columnPath),
+			CloneURL: field(record, columnCloneURL),
+			Error:    field(record, columnError),
+			Category: field(record, columnCategory),
+		}
+		if value := field(record, columnSize); value != "" {
+			if repo.Size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				slog.Warn("ignoring invalid size", "file", filename, "repo", name, "size", value)
+			}
+		}
+		if value := field(record, columnPriority); value != "" {
+			if repo.Priority, err = strconv.Atoi(value); err != nil {
+				slog.Warn("ignoring invalid priority", "file", filename, "repo", name, "priority", value)
+			}
+		}
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
+}
+
+// Write saves repositories to an inventory CSV file. The Size and Priority
+// columns are written when any repository has a value for them.
+func Write(filename string, repos []Repository) error {
+	header, row := inventoryColumns(repos)
+	return write(filename, header, repos, row)
+}
+
+// WriteFailures saves failed repositories to an inventory CSV file, with the
+// error text and category of each failure after the inventory columns. The
+// file can be passed back to pull or sync with --retry-failed.
+func WriteFailures(filename string, repos []Repository) error {
+	header, row := inventoryColumns(repos)
+	header = append(header, columnError, columnCategory)
+	return write(filename, header, repos, func(repo Repository) []string {
+		return append(row(repo), redact.String(repo.Error), repo.Category)
+	})
+}
+
+// inventoryColumns returns the inventory header for repos and a function
+// rendering a repository as a row of it
+func inventoryColumns(repos []Repository) ([]string, func(Repository) []string) {
+	var sizes, priorities bool
+	for _, repo := range repos {
+		sizes = sizes || repo.Size != 0
+		priorities = priorities || repo.Priority != 0
+	}
+
+	header := []string{columnRepository, columnPath, columnCloneURL}
+	if sizes {
+		header = append(header, columnSize)
+	}
+	if priorities {
+		header = append(header, columnPriority)
+	}
+
+	return header, func(repo Repository) []string {
+		row := []string{repo.Name, repo.Path, redact.String(repo.CloneURL)}
+		if sizes {
+			row = append(row, formatInt(repo.Size))
+		}
+		if priorities {
+			row = append(row, formatInt(int64(repo.Priority)))
+		}
+		return row
+	}
+}
+
+// formatInt leaves unset values empty
+func formatInt(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func write(filename string, header []string, repos []Repository, row func(Repository) []string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	// Write header
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	// Write data
+	for _, repo := range repos {
+		if err := writer.Write(row(repo)); err != nil {
+			return fmt.Errorf("error writing repository data: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
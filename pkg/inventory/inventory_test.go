@@ -0,0 +1,92 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readString(t *testing.T, content string) []Repository {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "repos.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repos, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repos
+}
+
+func TestReadByHeader(t *testing.T) {
+	repos := readString(t, "CloneURL,Repository,Priority,Size,Extra\n"+
+		"https://github.com/mona/a.git,a,5,1024,x\n"+
+		"https://github.com/mona/a.git,a,1,1,x\n"+
+		"https://github.com/mona/b.git,b,bad,,x\n")
+
+	want := []Repository{
+		{Name: "a", CloneURL: "https://github.com/mona/a.git", Priority: 5, Size: 1024},
+		{Name: "b", CloneURL: "https://github.com/mona/b.git"},
+	}
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Read() = %+v, want %+v", repos, want)
+	}
+}
+
+func TestReadByPosition(t *testing.T) {
+	want := []Repository{
+		{Name: "a", Path: ".gitattributes", CloneURL: "https://github.com/mona/a.git"},
+		{Name: "b", Path: ".gitattributes", CloneURL: "https://github.com/mona/b.git"},
+	}
+
+	// A header with other column names is skipped
+	repos := readString(t, "name,path,url\n"+
+		"a,.gitattributes,https://github.com/mona/a.git\n"+
+		"b,.gitattributes,https://github.com/mona/b.git\n")
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Read() with other header = %+v, want %+v", repos, want)
+	}
+
+	// Without a header the first row is a repository
+	repos = readString(t, "a,.gitattributes,https://github.com/mona/a.git\n"+
+		"b,.gitattributes,https://github.com/mona/b.git\n")
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Read() without header = %+v, want %+v", repos, want)
+	}
+}
+
+func TestWriteFailuresRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.csv")
+	failed := []Repository{
+		{Name: "a", Path: ".gitattributes", CloneURL: "https://github.com/mona/a.git", Size: 1024, Priority: 2, Error: "push failed", Category: "lfs"},
+		{Name: "b", Path: ".gitattributes", CloneURL: "https://github.com/mona/b.git", Error: "timed out", Category: "timeout"},
+	}
+	if err := WriteFailures(path, failed); err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repos, failed) {
+		t.Errorf("Read() = %+v, want %+v", repos, failed)
+	}
+}
+
+func TestWriteOmitsUnsetSchedulingColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.csv")
+	if err := Write(path, []Repository{{Name: "a", Path: ".gitattributes", CloneURL: "https://github.com/mona/a.git"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Repository,GitAttributesPaths,CloneURL\na,.gitattributes,https://github.com/mona/a.git\n"; string(data) != want {
+		t.Errorf("inventory = %q, want %q", data, want)
+	}
+}
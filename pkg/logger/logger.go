@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+)
+
+var logFile *os.File
+
+// console is where logs go when no log file is given. The progress dashboard
+// replaces it while it is shown, so that log lines are printed above it.
+var console = &consoleWriter{w: os.Stderr}
+
+type consoleWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (c *consoleWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.w.Write(p)
+}
+
+// SetConsole sends the logs meant for stderr to w until the returned function
+// is called. Logs written to a log file are not affected.
+func SetConsole(w io.Writer) (restore func()) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	previous := console.w
+	console.w = w
+	return func() {
+		console.mu.Lock()
+		defer console.mu.Unlock()
+		console.w = previous
+	}
+}
+
+// Setup configures the default logger. Logs are written to stderr, or only to
+// file when one is given. Stderr usually shares the terminal with the progress
+// dashboard on stdout, which prints them above itself while it is shown.
+// Secrets are masked in every message and attribute.
+func Setup(level, format, file string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.ToUpper(strings.TrimSpace(orDefault(level, "info"))))); err != nil {
+		return fmt.Errorf("invalid log level %q, use debug, info, warn or error", level)
+	}
+
+	var out io.Writer = console
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		logFile = f
+		out = f
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(orDefault(format, "text")) {
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, use text or json", format)
+	}
+
+	slog.SetDefault(slog.New(redactHandler{handler}))
+	return nil
+}
+
+// Close closes the log file, if any
+func Close() {
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
+
+// ForRepo returns a logger that adds the repository and phase to every line
+func ForRepo(repo, phase string) *slog.Logger {
+	return slog.Default().With("repo", repo, "phase", phase)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// redactHandler masks secrets in log messages and attribute values
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, redact.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		record.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact.String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]any, len(group))
+		for i, ga := range group {
+			attrs[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, attrs...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, redact.Error(err))
+		}
+		return slog.String(a.Key, redact.String(fmt.Sprint(v.Any())))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetConsole(t *testing.T) {
+	if err := Setup("info", "text", ""); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	restore := SetConsole(&buf)
+	slog.Info("shown above the dashboard", "repo", "repo")
+	restore()
+	slog.Info("written to stderr again")
+
+	got := buf.String()
+	if !strings.Contains(got, "shown above the dashboard") || strings.Contains(got, "stderr again") {
+		t.Errorf("console output = %q", got)
+	}
+}
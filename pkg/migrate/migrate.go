@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	stdsync "sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/export"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/pull"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/report"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/sync"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// MigrateLFS runs export, pull and sync as one pipeline. Export runs first so
+// that the repositories can be scheduled like for pull and sync, then each
+// repository is synced as soon as its pull finishes. The inventory and the
+// phases already completed in the state store are reused, so an interrupted
+// run can be resumed by running it again.
+func MigrateLFS(ctx context.Context) error {
+	start := time.Now()
+
+	sourceOrg := viper.GetString("GHMLFS_SOURCE_ORGANIZATION")
+	sourceToken := viper.GetString("GHMLFS_SOURCE_TOKEN")
+	sourceHostname := viper.GetString("GHMLFS_SOURCE_HOSTNAME")
+	depth := viper.GetInt("GHMLFS_SEARCH_DEPTH")
+	targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
+	targetToken := viper.GetString("GHMLFS_TARGET_TOKEN")
+	targetHostname := viper.GetString("GHMLFS_TARGET_HOSTNAME")
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	branchMode := viper.GetBool("GHMLFS_BRANCH_MODE")
+	force := viper.GetBool("GHMLFS_FORCE")
+	pullWorkers := viper.GetInt("GHMLFS_PULL_WORKERS")
+	syncWorkers := viper.GetInt("GHMLFS_SYNC_WORKERS")
+
+	if depth == 0 {
+		depth = 1 // Default depth if not specified
+	}
+
+	adaptive := viper.GetBool("GHMLFS_ADAPTIVE_WORKERS")
+	minWorkers := viper.GetInt("GHMLFS_MIN_WORKERS")
+	maxWorkers := viper.GetInt("GHMLFS_MAX_WORKERS")
+	pullConcurrency, err := common.NewConcurrency(pullWorkers, adaptive, minWorkers, maxWorkers)
+	if err != nil {
+		return err
+	}
+	syncConcurrency, err := common.NewConcurrency(syncWorkers, adaptive, minWorkers, maxWorkers)
+	if err != nil {
+		return err
+	}
+	if adaptive {
+		pterm.Info.Printf("Workers: pull %s, sync %s\n", pullConcurrency, syncConcurrency)
+	}
+
+	if branchMode {
+		pterm.Info.Printf("Mode: Branching\n")
+	} else {
+		pterm.Info.Printf("Mode: Mirroring\n")
+	}
+
+	store, err := state.Open(workDir)
+	if err != nil {
+		return err
+	}
+
+	timeouts, err := common.ParseTimeouts(
+		viper.GetString("GHMLFS_REPO_TIMEOUT"),
+		viper.GetString("GHMLFS_OPERATION_TIMEOUT"),
+		viper.GetString("GHMLFS_STALL_TIMEOUT"),
+		viper.GetInt("GHMLFS_TIMEOUT_RETRIES"),
+	)
+	if err != nil {
+		return err
+	}
+	common.SetGitTimeouts(timeouts)
+
+	if viper.GetBool("GHMLFS_DEBUG") {
+		if err := common.EnableGitDebug(workDir); err != nil {
+			return err
+		}
+	}
+
+	refs, err := common.ParseRefFilter(viper.GetString("GHMLFS_INCLUDE_REFS"),
+		viper.GetString("GHMLFS_EXCLUDE_REFS"), viper.GetString("GHMLFS_REFS_SINCE"))
+	if err != nil {
+		return err
+	}
+
+	tagOrder, err := sync.ParseTagOrder(viper.GetString("GHMLFS_TAG_ORDER"))
+	if err != nil {
+		return err
+	}
+
+	maxObjectSize, err := sync.ParseMaxObjectSize(viper.GetString("GHMLFS_MAX_OBJECT_SIZE"))
+	if err != nil {
+		return err
+	}
+	oversizedPolicy, err := sync.ParseOversizedPolicy(viper.GetString("GHMLFS_OVERSIZED_POLICY"))
+	if err != nil {
+		return err
+	}
+	if maxObjectSize > 0 {
+		pterm.Info.Printf("Maximum LFS object size: %s (%s oversized objects)\n", common.FormatBytes(maxObjectSize), oversizedPolicy)
+	}
+
+	blobFilter, err := common.BlobFilter(viper.GetString("GHMLFS_BLOB_LIMIT"))
+	if err != nil {
+		return err
+	}
+
+	var objects *common.LFSStore
+	if viper.GetBool("GHMLFS_SHARED_LFS_STORE") {
+		if objects, err = common.OpenLFSStore(workDir); err != nil {
+			return err
+		}
+		pterm.Info.Printf("Shared LFS store: %s\n", filepath.Join(workDir, common.LFSStoreDirName))
+	}
+
+	pullStats := common.NewProcessStats()
+	syncStats := common.NewProcessStats()
+
+	puller := &pull.Puller{
+		Token:      sourceToken,
+		WorkDir:    workDir,
+		BranchMode: branchMode,
+		Force:      force,
+		Timeouts:   timeouts,
+		BlobFilter: blobFilter,
+		Refs:       refs,
+		Objects:    objects,
+		Store:      store,
+		Stats:      pullStats,
+	}
+	syncer := &sync.Syncer{
+		Token:           targetToken,
+		WorkDir:         workDir,
+		TargetOrg:       targetOrg,
+		Hostname:        targetHostname,
+		BranchMode:      branchMode,
+		AllowUnarchive:  viper.GetBool("GHMLFS_UNARCHIVE"),
+		Force:           force,
+		Refs:            refs,
+		TagOrder:        tagOrder,
+		AllowIncomplete: viper.GetBool("GHMLFS_ALLOW_INCOMPLETE_PUSH"),
+		MaxObjectSize:   maxObjectSize,
+		OversizedPolicy: oversizedPolicy,
+		Verify:          !viper.GetBool("GHMLFS_SKIP_VERIFY"),
+		Timeouts:        timeouts,
+		Store:           store,
+		Stats:           syncStats,
+	}
+
+	repos, err := exportRepositories(ctx, workDir, sourceOrg, sourceToken, sourceHostname, depth)
+	if err != nil {
+		return err
+	}
+	repos = common.Schedule(repos, workDir)
+
+	pullJobs := make(chan inventory.Repository)
+	syncJobs := make(chan inventory.Repository)
+	go func() {
+		defer close(pullJobs)
+		for _, repo := range repos {
+			pullJobs <- repo
+		}
+	}()
+
+	// Every repository adds a pull and a sync to the dashboard
+	dashboard := common.StartDashboard("Migrating repositories", 2*len(repos))
+	ctx = common.WithDashboard(ctx, dashboard)
+
+	// Pull workers hand every pulled repository over to the sync workers
+	// without waiting for a sync worker to become free
+	var pullErr error
+	go func() {
+		var handoff stdsync.WaitGroup
+		defer func() {
+			handoff.Wait()
+			close(syncJobs)
+		}()
+
+		pullErr = common.RunWorkers(ctx, pullJobs, pullConcurrency, pullStats, func(ctx context.Context, repo inventory.Repository) error {
+			err := puller.Pull(ctx, repo)
+			if err == nil || errors.Is(err, common.ErrSkipped) {
+				handoff.Add(1)
+				go func() {
+					defer handoff.Done()
+					syncJobs <- repo
+				}()
+			} else {
+				// A failed pull is not synced
+				dashboard.AddTotal(-1)
+			}
+			return err
+		})
+	}()
+
+	syncErr := common.RunWorkers(ctx, syncJobs, syncConcurrency, syncStats, syncer.Sync)
+	dashboard.Stop()
+
+	pullStats.SaveFailures(workDir, "pull")
+	syncStats.SaveFailures(workDir, "sync")
+	pullStats.SaveMissingObjects(workDir, "pull")
+	syncStats.SaveMissingObjects(workDir, "sync")
+	report.Save(viper.GetString("GHMLFS_REPORT"), viper.GetString("GHMLFS_REPORT_FORMAT"), "migrate", pullStats, syncStats)
+
+	fmt.Printf("\n📦 Pull phase:")
+	pullStats.PrintSummary("")
+	fmt.Printf("\n🚀 Sync phase:")
+	syncStats.PrintSummary(workDir)
+
+	if err := errors.Join(pullErr, syncErr); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✅ Migration completed successfully in %v!\n", time.Since(start).Round(time.Second))
+	return nil
+}
+
+// exportRepositories returns the LFS repositories of the source organization.
+// The inventory of an earlier run in the work dir is reused, otherwise the
+// organization is exported and the inventory written to the work dir, unless
+// some repositories could not be checked, and to {organization}_lfs.csv
+// before any pull starts.
+func exportRepositories(ctx context.Context, workDir, sourceOrg, token, hostname string, depth int) ([]inventory.Repository, error) {
+	stored := filepath.Join(workDir, fmt.Sprintf(".%s_lfs.csv", sourceOrg))
+	if _, err := os.Stat(stored); err == nil {
+		repos, err := inventory.Read(stored)
+		if err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Using the %d repositories exported by an earlier run in %s, delete it to export again\n", len(repos), stored)
+		return repos, nil
+	}
+
+	// Stop searching on the first interrupt, a partial inventory is not written
+	spinner := common.StartSpinner("Searching for repositories with LFS content...")
+	result, err := export.SearchLFSRepos(common.Draining(ctx), sourceOrg, token, hostname, depth)
+	if err != nil {
+		spinner.Fail()
+		return nil, err
+	}
+	spinner.Success()
+
+	// Repositories that could not be checked are looked at again on resume
+	if result.Failed > 0 {
+		pterm.Warning.Printf("Failed to check %d repositories for LFS content, they are not migrated\n", result.Failed)
+	} else if err := inventory.Write(stored, result.Repos); err != nil {
+		return nil, fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	outputFile := sourceOrg + "_lfs.csv"
+	if err := inventory.Write(outputFile, result.Repos); err != nil {
+		pterm.Warning.Printf("Failed to write CSV file: %v\n", err)
+	} else {
+		pterm.Info.Printf("Exported %d repositories with LFS to %s\n", len(result.Repos), outputFile)
+	}
+	return result.Repos, nil
+}
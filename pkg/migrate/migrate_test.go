@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+)
+
+func TestExportRepositoriesReusesInventory(t *testing.T) {
+	workDir := t.TempDir()
+	repos := []inventory.Repository{
+		{Name: "small", Path: ".gitattributes", CloneURL: "https://github.com/mona/small.git", Size: 1},
+		{Name: "large", Path: ".gitattributes", CloneURL: "https://github.com/mona/large.git", Size: 2},
+	}
+	if err := inventory.Write(filepath.Join(workDir, ".mona_lfs.csv"), repos); err != nil {
+		t.Fatal(err)
+	}
+
+	// No token or API is needed when the inventory is reused
+	got, err := exportRepositories(context.Background(), workDir, "mona", "", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "small" || got[1].Name != "large" {
+		t.Errorf("exportRepositories() = %+v", got)
+	}
+}
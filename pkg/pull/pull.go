@@ -1,34 +1,48 @@
 package pull
 
 import (
-    "encoding/csv"
+    "context"
     "fmt"
-    "io"
+    "log/slog"
     "os"
-    "os/exec"
     "path/filepath"
+    "slices"
     "strings"
+    "time"
 
     "github.com/mona-actions/gh-migrate-lfs/pkg/common"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/logger"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/report"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/state"
     "github.com/pterm/pterm"
     "github.com/spf13/viper"
 )
 
-type pullJob struct {
-    name     string
-    cloneURL string
-}
+// Objects are fetched in chunks of at most this many objects or bytes, and the
+// objects of each chunk are recorded in the pull checkpoint, so that an
+// interrupted pull only repeats the chunk in flight
+const (
+    pullChunkObjects = 100
+    pullChunkBytes   = 1 << 30
+)
 
-func PullLFSFromCSV() error {
+func PullLFSFromCSV(ctx context.Context) error {
     inputFile := viper.GetString("GHMLFS_FILE")
     token := viper.GetString("GHMLFS_SOURCE_TOKEN")
     workDir := viper.GetString("GHMLFS_WORK_DIR")
     maxWorkers := viper.GetInt("GHMLFS_WORKERS")
     branchMode := viper.GetBool("GHMLFS_BRANCH_MODE")
+    force := viper.GetBool("GHMLFS_FORCE")
 
-    // Ensure at least 1 worker
-    if maxWorkers <= 0 {
-        maxWorkers = 1
+    workers, err := common.NewConcurrency(maxWorkers, viper.GetBool("GHMLFS_ADAPTIVE_WORKERS"),
+        viper.GetInt("GHMLFS_MIN_WORKERS"), viper.GetInt("GHMLFS_MAX_WORKERS"))
+    if err != nil {
+        return err
+    }
+    if workers.Adaptive {
+        pterm.Info.Printf("Workers: %s\n", workers)
     }
 
 	if branchMode {
@@ -37,68 +51,88 @@ func PullLFSFromCSV() error {
 		pterm.Info.Printf("Mode: Mirroring\n")
 	}
 
-    // Read CSV file
-    file, err := os.Open(inputFile)
+    store, err := state.Open(workDir)
     if err != nil {
-        return fmt.Errorf("error opening input file: %w", err)
+        return err
     }
-    defer file.Close()
 
-    reader := csv.NewReader(file)
-    // Skip header
-    if _, err := reader.Read(); err != nil {
-        return fmt.Errorf("error reading CSV header: %w", err)
+    timeouts, err := common.ParseTimeouts(
+        viper.GetString("GHMLFS_REPO_TIMEOUT"),
+        viper.GetString("GHMLFS_OPERATION_TIMEOUT"),
+        viper.GetString("GHMLFS_STALL_TIMEOUT"),
+        viper.GetInt("GHMLFS_TIMEOUT_RETRIES"),
+    )
+    if err != nil {
+        return err
     }
+    common.SetGitTimeouts(timeouts)
 
-    // Create jobs channel and track unique repositories
-    jobs := make(chan pullJob)
-    seen := make(map[string]bool)
+    if viper.GetBool("GHMLFS_DEBUG") {
+        if err := common.EnableGitDebug(workDir); err != nil {
+            return err
+        }
+    }
+
+    // Re-run only the repositories of a previous failures file when given
+    if retryFile := viper.GetString("GHMLFS_RETRY_FAILED"); retryFile != "" {
+        pterm.Info.Printf("Retrying failed repositories from %s\n", retryFile)
+        inputFile = retryFile
+    }
+
+    // Read CSV file
+    repos, err := inventory.Read(inputFile)
+    if err != nil {
+        return err
+    }
+    repos = common.Schedule(repos, workDir)
 
     // Start goroutine to send jobs
+    jobs := make(chan inventory.Repository)
     go func() {
         defer close(jobs)
-        for {
-            record, err := reader.Read()
-            if err != nil {
-                if err == io.EOF {
-                    break
-                }
-                fmt.Printf("Error reading CSV record: %v\n", err)
-                continue
-            }
-            if len(record) != 3 {
-                fmt.Printf("Invalid CSV record format, expected 3 columns got %d\n", len(record))
-                continue
-            }
+        for _, repo := range repos {
+            jobs <- repo
+        }
+    }()
 
-            repoName := record[0]
-            if seen[repoName] {
-                continue
-            }
-            seen[repoName] = true
+    refs, err := common.ParseRefFilter(viper.GetString("GHMLFS_INCLUDE_REFS"),
+        viper.GetString("GHMLFS_EXCLUDE_REFS"), viper.GetString("GHMLFS_REFS_SINCE"))
+    if err != nil {
+        return err
+    }
 
-            jobs <- pullJob{
-                name:     repoName,
-                cloneURL: record[2],
-            }
+    blobFilter, err := common.BlobFilter(viper.GetString("GHMLFS_BLOB_LIMIT"))
+    if err != nil {
+        return err
+    }
+
+    var objects *common.LFSStore
+    if viper.GetBool("GHMLFS_SHARED_LFS_STORE") {
+        if objects, err = common.OpenLFSStore(workDir); err != nil {
+            return err
         }
-    }()
+        pterm.Info.Printf("Shared LFS store: %s\n", filepath.Join(workDir, common.LFSStoreDirName))
+    }
 
     // Create and run worker pool
     stats := common.NewProcessStats()
-    err = common.WorkerPool(jobs, maxWorkers, stats, func(job pullJob) error {
-        // Authenticate URL here, in the worker
-        urlParts := strings.SplitN(job.cloneURL, "://", 2)
-        if len(urlParts) != 2 {
-            return fmt.Errorf("invalid clone URL format for %s", job.name)
-        }
-        authenticatedURL := fmt.Sprintf("%s://%s@%s", urlParts[0], token, urlParts[1])
+    puller := &Puller{
+        Token:      token,
+        WorkDir:    workDir,
+        BranchMode: branchMode,
+        Force:      force,
+        Timeouts:   timeouts,
+        BlobFilter: blobFilter,
+        Refs:       refs,
+        Objects:    objects,
+        Store:      store,
+        Stats:      stats,
+    }
+    err = common.WorkerPool(ctx, jobs, len(repos), workers, stats, puller.Pull)
 
-        if branchMode {
-            return PullLFSContentBranchMode(job.name, authenticatedURL, token, workDir)
-        }
-        return PullLFSContentMirrorMode(job.name, authenticatedURL, token, workDir)
-    })
+    stats.SaveFailures(workDir, "pull")
+    stats.SaveMissingObjects(workDir, "pull")
+    report.Save(viper.GetString("GHMLFS_REPORT"), viper.GetString("GHMLFS_REPORT_FORMAT"), "pull", stats)
 
     // Print summary
     stats.PrintSummary(workDir)
@@ -111,88 +145,448 @@ func PullLFSFromCSV() error {
     return nil
 }
 
-func PullLFSContentMirrorMode(repoName, cloneURL, token, workDir string) error {
+// Puller clones repositories and fetches their LFS objects, recording each
+// repository's progress in the state store
+type Puller struct {
+    Token      string
+    WorkDir    string
+    BranchMode bool
+    Force      bool
+    Timeouts   common.Timeouts
+    BlobFilter string           // partial clone filter argument, empty for full clones
+    Refs       common.RefFilter // refs whose LFS objects are fetched
+    Objects    *common.LFSStore // shared LFS object store, nil when disabled
+    Store      *state.Store
+    Stats      *common.ProcessStats
+}
+
+// Pull pulls a single repository, returning common.ErrSkipped when it was
+// already pulled and Force is not set
+func (p *Puller) Pull(ctx context.Context, repo inventory.Repository) error {
+    repoPath := filepath.Join(p.WorkDir, repo.Name)
+    log := logger.ForRepo(repo.Name, state.PhasePull)
+    result := common.RepoResult{
+        Repository: repo,
+        Phase:      state.PhasePull,
+        Mode:       common.ModeName(p.BranchMode),
+        StartTime:  time.Now(),
+    }
+    defer func() {
+        result.Duration = time.Since(result.StartTime)
+        p.Stats.RecordResult(result)
+    }()
+
+    // Objects quarantined by verify-local are fetched again even after a
+    // completed pull
+    refetch := common.RefetchQueue(p.WorkDir, repo.Name)
+    if !p.Force && len(refetch) == 0 && p.Store.IsCompleted(repo.Name, state.PhasePull, redact.String(repo.CloneURL)) {
+        log.Info("skipping repository, pull already completed (use --force to pull again)")
+        result.Status = common.StatusSkipped
+        return common.ErrSkipped
+    }
+
+    if err := p.Store.Start(repo.Name, state.PhasePull, redact.String(repo.CloneURL)); err != nil {
+        log.Warn("failed to update state", "error", err)
+    }
+
+    // Forcing a pull, or fetching quarantined objects, fetches every ref again
+    if p.Force || len(refetch) > 0 {
+        if err := common.RemoveCheckpoint(p.WorkDir, repo.Name, state.PhasePull); err != nil {
+            log.Warn("failed to remove checkpoint", "error", err)
+        }
+    }
+
+    ctx, task := common.StartTask(ctx, repo.Name, state.PhasePull)
+    defer task.Done()
+
+    if len(refetch) > 0 {
+        log.Info("fetching quarantined LFS objects again", "objects", len(refetch))
+    }
+
+    // Objects already present locally were not transferred by this run.
+    // git-lfs keeps each object once complete and never fetches it again, so
+    // an interrupted pull resumes with the objects left.
+    objectsBefore, bytesBefore, _ := common.LFSObjectStats(repoPath)
+    if objectsBefore > 0 {
+        log.Info("resuming pull, objects fetched before are skipped", "objects", objectsBefore, "bytes", common.FormatBytes(bytesBefore))
+    }
+
+    var fetched FetchResult
+    err := p.Timeouts.Run(ctx, log, func(ctx context.Context) error {
+        var err error
+        fetched, err = p.pull(ctx, repo.Name, repo.CloneURL)
+        return err
+    })
+    if err != nil {
+        result.SetError(err)
+        log.Error("pull failed", "error", err, "category", result.Category)
+        if stateErr := p.Store.Fail(repo.Name, state.PhasePull, err); stateErr != nil {
+            log.Warn("failed to update state", "error", stateErr)
+        }
+        return err
+    }
+
+    if len(refetch) > 0 {
+        if left, err := common.PruneRefetchQueue(p.WorkDir, repo.Name); err != nil {
+            log.Warn("failed to update re-fetch queue", "error", err)
+        } else if left > 0 {
+            log.Warn("quarantined LFS objects were not fetched again", "objects", left)
+        }
+    }
+
+    collected, err := state.CollectResult(ctx, repo.Name, repoPath)
+    if err != nil {
+        log.Warn("failed to collect pull results", "error", err)
+    }
+    collected.Missing = fetched.Missing
+    if err := p.Store.Complete(repo.Name, state.PhasePull, collected); err != nil {
+        log.Warn("failed to update state", "error", err)
+    }
+
+    result.Status = common.StatusSuccess
+    result.Refs = len(collected.Refs)
+    // Objects linked from the shared store were not downloaded either
+    result.Objects = max(collected.Objects-objectsBefore-fetched.Reused, 0)
+    result.Bytes = max(collected.Bytes-bytesBefore-fetched.ReusedBytes, 0)
+    result.Missing = fetched.Missing
+    log.Info("pull completed", "refs", result.Refs, "objects", result.Objects, "bytes", result.Bytes, "reused", fetched.Reused, "duration", time.Since(result.StartTime).Round(time.Millisecond).String())
+    return nil
+}
+
+func (p *Puller) pull(ctx context.Context, repoName, cloneURL string) (FetchResult, error) {
+    // Authenticate URL here, in the worker
+    urlParts := strings.SplitN(cloneURL, "://", 2)
+    if len(urlParts) != 2 {
+        return FetchResult{}, fmt.Errorf("invalid clone URL format for %s", repoName)
+    }
+    authenticatedURL := fmt.Sprintf("%s://%s@%s", urlParts[0], p.Token, urlParts[1])
+
+    if p.BranchMode {
+        return PullLFSContentBranchMode(ctx, repoName, authenticatedURL, p.Token, p.WorkDir, p.BlobFilter, p.Refs, p.Objects)
+    }
+    return PullLFSContentMirrorMode(ctx, repoName, authenticatedURL, p.Token, p.WorkDir, p.BlobFilter, p.Refs, p.Objects)
+}
+
+func PullLFSContentMirrorMode(ctx context.Context, repoName, cloneURL, token, workDir, blobFilter string, refs common.RefFilter, objects *common.LFSStore) (FetchResult, error) {
     repoPath := filepath.Join(workDir, repoName)
+    log := logger.ForRepo(repoName, state.PhasePull)
 
     // Create working directory if it doesn't exist
     if err := os.MkdirAll(workDir, 0755); err != nil {
-        return fmt.Errorf("❌ Failed to create working directory: %w", err)
+        return FetchResult{}, fmt.Errorf("❌ Failed to create working directory: %w", err)
     }
 
     // Check if the repository already exists
     if _, err := os.Stat(repoPath); err == nil {
-        pterm.Info.Printf("Repository exists '%s', proceeding with update\n", repoName)
+        log.Info("repository exists, proceeding with update")
 
-        pullCmd := exec.Command("git", "fetch", "--prune", "origin", "+refs/*:refs/*")
-        pullCmd.Dir = repoPath
-        if output, err := pullCmd.CombinedOutput(); err != nil {
-            return fmt.Errorf("❌ Failed to pull updates: %s, %w", string(output), err)
+        git := common.NewGitCommand(ctx, repoName, repoPath, nil)
+        if err := resetOrigin(git, cloneURL); err != nil {
+            return FetchResult{}, err
+        }
+        if output, err := git.CombinedOutput("fetch", "--prune", "origin", "+refs/*:refs/*"); err != nil {
+            return FetchResult{}, fmt.Errorf("❌ Failed to pull updates: %s, %w", redact.String(string(output)), err)
         }
 
-        lfsPullCmd := exec.Command("git", "lfs", "fetch", "--all")
-        lfsPullCmd.Dir = repoPath
-        if output, err := lfsPullCmd.CombinedOutput(); err != nil {
-            return fmt.Errorf("❌ Failed to pull LFS content: %s, %w", string(output), err)
+        fetched, err := fetchLFSObjects(log, git, workDir, cloneURL, refs, objects)
+        if err != nil {
+            return FetchResult{}, err
         }
 
-        pterm.Success.Printf("Synchronization with '%s' completed successfully\n", repoName)
-        return nil
+        log.Info("synchronization completed successfully")
+        return fetched, nil
     }
 
-    pterm.Info.Printf("Cloning repository '%s'...\n", repoName)
-    cloneCmd := exec.Command("git", "clone", "--mirror", "--bare", cloneURL, repoName)
-    cloneCmd.Dir = workDir
-    if output, err := cloneCmd.CombinedOutput(); err != nil {
-        errMsg := strings.ReplaceAll(string(output), token, "****")
-        return fmt.Errorf("❌ Failed to clone repository: %s, %w", errMsg, err)
+    log.Info("cloning repository")
+    if output, err := common.NewGitCommand(ctx, repoName, workDir, nil).CombinedOutput(cloneArgs(blobFilter, "--mirror", "--bare", cloneURL, repoName)...); err != nil {
+        errMsg := redact.String(string(output))
+        return FetchResult{}, fmt.Errorf("❌ Failed to clone repository: %s, %w", errMsg, err)
     }
 
-    pterm.Info.Printf("Pulling LFS objects for repository '%s'...\n", repoName)
+    log.Info("pulling LFS objects")
 
-    lfsPullCmd := exec.Command("git", "lfs", "fetch", "--all")
-    lfsPullCmd.Dir = repoPath
-    if output, err := lfsPullCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("❌ Failed to fetch LFS content: %s, %w", string(output), err)
+    fetched, err := fetchLFSObjects(log, common.NewGitCommand(ctx, repoName, repoPath, nil), workDir, cloneURL, refs, objects)
+    if err != nil {
+        return FetchResult{}, err
     }
 
-    pterm.Success.Printf("synchronized: %s\n", repoName)
-    return nil
+    log.Info("synchronized")
+    return fetched, nil
 }
 
-func PullLFSContentBranchMode(repoName, cloneURL, token, workDir string) error {
+func PullLFSContentBranchMode(ctx context.Context, repoName, cloneURL, token, workDir, blobFilter string, refs common.RefFilter, objects *common.LFSStore) (FetchResult, error) {
     repoPath := filepath.Join(workDir, repoName)
+    log := logger.ForRepo(repoName, state.PhasePull)
 
     // Create working directory if it doesn't exist
     if err := os.MkdirAll(workDir, 0755); err != nil {
-        return fmt.Errorf("❌ Failed to create working directory: %w", err)
+        return FetchResult{}, fmt.Errorf("❌ Failed to create working directory: %w", err)
     }
 
     // Check if the repository already exists
     if _, err := os.Stat(repoPath); err == nil {
-        pterm.Info.Printf("Repository exists '%s', proceeding with update\n", repoName)
-        
-        fetchCmd := exec.Command("git", "fetch", "--all")
-        fetchCmd.Dir = repoPath
-        if output, err := fetchCmd.CombinedOutput(); err != nil {
-            return fmt.Errorf("❌ Failed to fetch updates: %s, %w", string(output), err)
+        log.Info("repository exists, proceeding with update")
+
+        git := common.NewGitCommand(ctx, repoName, repoPath, nil)
+        if err := resetOrigin(git, cloneURL); err != nil {
+            return FetchResult{}, err
+        }
+        if output, err := git.CombinedOutput("fetch", "--all"); err != nil {
+            return FetchResult{}, fmt.Errorf("❌ Failed to fetch updates: %s, %w", redact.String(string(output)), err)
         }
     } else {
-        pterm.Info.Printf("Cloning repository '%s'...\n", repoName)
-        cloneCmd := exec.Command("git", "clone", cloneURL)
-        cloneCmd.Dir = workDir
-        if output, err := cloneCmd.CombinedOutput(); err != nil {
-            errMsg := strings.ReplaceAll(string(output), token, "****")
-            return fmt.Errorf("❌ Failed to clone repository: %s, %w", errMsg, err)
+        log.Info("cloning repository")
+        if output, err := common.NewGitCommand(ctx, repoName, workDir, nil).CombinedOutput(cloneArgs(blobFilter, cloneURL)...); err != nil {
+            errMsg := redact.String(string(output))
+            return FetchResult{}, fmt.Errorf("❌ Failed to clone repository: %s, %w", errMsg, err)
         }
     }
 
     // Pull LFS content for all branches
-    lfsPullCmd := exec.Command("git", "lfs", "fetch", "--all")
-    lfsPullCmd.Dir = repoPath
-    if output, err := lfsPullCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("❌ Failed to pull LFS content: %s, %w", string(output), err)
+    fetched, err := fetchLFSObjects(log, common.NewGitCommand(ctx, repoName, repoPath, nil), workDir, cloneURL, refs, objects)
+    if err != nil {
+        return FetchResult{}, err
     }
 
-    pterm.Success.Printf("synchronized: %s\n", repoName)
+    log.Info("synchronized")
+    return fetched, nil
+}
+
+// resetOrigin points origin of an existing clone back at the source. Sync
+// sets it to the target, so an update after a sync would fetch from there.
+func resetOrigin(git *common.GitCommand, cloneURL string) error {
+    if output, err := git.CombinedOutput("remote", "set-url", "origin", cloneURL); err != nil {
+        return fmt.Errorf("❌ Failed to set source remote: %s, %w", redact.String(string(output)), err)
+    }
     return nil
-}
\ No newline at end of file
+}
+
+// cloneArgs builds the arguments of git clone, adding the partial clone
+// filter when one is set
+func cloneArgs(blobFilter string, args ...string) []string {
+    cloneArgs := []string{"clone"}
+    if blobFilter != "" {
+        cloneArgs = append(cloneArgs, blobFilter)
+    }
+    return append(cloneArgs, args...)
+}
+
+// FetchResult is what fetching the LFS objects of a repository found
+type FetchResult struct {
+    Missing     []common.MissingObject // objects the source server does not have
+    Reused      int                    // objects linked from the shared store
+    ReusedBytes int64
+}
+
+// fetchLFSObjects fetches the LFS objects in the history of the refs selected
+// by the filter, reusing the ones the shared store already has. Objects are
+// fetched in chunks recorded in the pull checkpoint, so that an interrupted
+// pull skips the objects it fetched already. Objects the source server does
+// not have are returned rather than failing the fetch.
+func fetchLFSObjects(log *slog.Logger, git *common.GitCommand, workDir, cloneURL string, refs common.RefFilter, objects *common.LFSStore) (FetchResult, error) {
+    selected, err := refs.SelectRefs(git)
+    if err != nil {
+        return FetchResult{}, err
+    }
+    if len(selected) == 0 {
+        if !refs.IsZero() {
+            log.Warn("no refs match the ref filters, skipping LFS fetch")
+        }
+        return FetchResult{}, nil
+    }
+    if !refs.IsZero() {
+        log.Info("fetching LFS objects of selected refs", "refs", len(selected))
+    }
+
+    pointers, err := common.ListLFSPointers(git, selected...)
+    if err != nil {
+        return FetchResult{}, err
+    }
+    pointers = uniquePointers(pointers)
+
+    var result FetchResult
+    result.Reused, result.ReusedBytes = reuseSharedObjects(log, git, objects, pointers)
+
+    // The checkpoint is keyed by the source without its credentials
+    checkpoint, err := common.OpenCheckpoint(workDir, git.Repo, state.PhasePull, redact.String(cloneURL))
+    if err != nil {
+        return FetchResult{}, err
+    }
+    defer checkpoint.Close()
+
+    // Objects recorded in the checkpoint, or otherwise present locally, such as
+    // the ones linked from the shared store, are not fetched again
+    var pending []common.LFSPointer
+    for _, pointer := range pointers {
+        if len(checkpoint.Pending([]string{pointer.OID})) > 0 && !common.HasLFSObject(git.Dir, pointer.OID) {
+            pending = append(pending, pointer)
+        }
+    }
+    if done := len(pointers) - len(pending); done > 0 {
+        log.Info("skipping LFS objects present locally", "objects", done, "remaining", len(pending))
+    }
+    var size int64
+    for _, pointer := range pending {
+        size += pointer.Size
+    }
+    common.TaskFrom(git.Ctx).SetTotals(len(pending), size)
+
+    for _, chunk := range chunkPointers(pending, pullChunkObjects, pullChunkBytes) {
+        commit, err := pointerCommit(git, chunk)
+        if err != nil {
+            return FetchResult{}, err
+        }
+        if output, err := git.CombinedOutput("lfs", "fetch", "origin", commit); err != nil {
+            chunkMissing := missingOnSource(git, chunk, output)
+            if chunkMissing == nil {
+                return FetchResult{}, fmt.Errorf("❌ Failed to fetch LFS content: %s, %w", redact.String(string(output)), err)
+            }
+            result.Missing = appendMissing(result.Missing, chunkMissing)
+        }
+
+        // Objects missing on source are not recorded, so that a resumed pull
+        // reports them again
+        var fetched []string
+        for _, pointer := range chunk {
+            if common.HasLFSObject(git.Dir, pointer.OID) {
+                fetched = append(fetched, pointer.OID)
+            }
+        }
+        if err := checkpoint.Record(fetched); err != nil {
+            log.Warn("failed to update checkpoint", "error", err)
+        }
+    }
+    if len(result.Missing) > 0 {
+        log.Warn("LFS objects missing on source, fetched the other objects", "objects", len(result.Missing))
+        for _, object := range result.Missing {
+            log.Debug("LFS object missing on source", "oid", object.OID, "path", object.Path, "commit", object.Commit)
+        }
+    }
+    storeSharedObjects(log, objects, git.Dir)
+
+    if err := checkpoint.Remove(); err != nil {
+        log.Warn("failed to remove checkpoint", "error", err)
+    }
+    return result, nil
+}
+
+// uniquePointers keeps the first pointer of each object
+func uniquePointers(pointers []common.LFSPointer) []common.LFSPointer {
+    seen := make(map[string]bool)
+    var unique []common.LFSPointer
+    for _, pointer := range pointers {
+        if !seen[pointer.OID] {
+            seen[pointer.OID] = true
+            unique = append(unique, pointer)
+        }
+    }
+    return unique
+}
+
+// chunkPointers splits pointers into chunks of at most maxCount objects and
+// about maxBytes of content, as recorded in the pointers
+func chunkPointers(pointers []common.LFSPointer, maxCount int, maxBytes int64) [][]common.LFSPointer {
+    var chunks [][]common.LFSPointer
+    var chunk []common.LFSPointer
+    var size int64
+    for _, pointer := range pointers {
+        chunk = append(chunk, pointer)
+        size += pointer.Size
+        if len(chunk) >= maxCount || size >= maxBytes {
+            chunks = append(chunks, chunk)
+            chunk, size = nil, 0
+        }
+    }
+    if len(chunk) > 0 {
+        chunks = append(chunks, chunk)
+    }
+    return chunks
+}
+
+// pointerCommit writes a commit whose tree holds the pointer blobs, named by
+// their OID, so that git lfs fetch of that commit fetches exactly their
+// objects. The commit is not referenced by any ref and git gc prunes it.
+func pointerCommit(git *common.GitCommand, pointers []common.LFSPointer) (string, error) {
+    var tree strings.Builder
+    for _, pointer := range pointers {
+        fmt.Fprintf(&tree, "100644 blob %s\t%s\n", pointer.Blob, pointer.OID)
+    }
+    output, err := git.InputOutput([]byte(tree.String()), "mktree")
+    if err != nil {
+        return "", fmt.Errorf("failed to write LFS pointer tree: %w", err)
+    }
+
+    output, err = git.Output("-c", "user.name=gh-migrate-lfs", "-c", "user.email=gh-migrate-lfs@localhost",
+        "commit-tree", strings.TrimSpace(string(output)), "-m", "LFS objects to fetch")
+    if err != nil {
+        return "", fmt.Errorf("failed to write LFS pointer commit: %w", err)
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// appendMissing adds the objects of more that objects does not list yet
+func appendMissing(objects, more []common.MissingObject) []common.MissingObject {
+    for _, object := range more {
+        if !slices.ContainsFunc(objects, func(o common.MissingObject) bool { return o.OID == object.OID }) {
+            objects = append(objects, object)
+        }
+    }
+    return objects
+}
+
+// missingOnSource explains a failed LFS fetch of the objects of pointers by
+// objects the source server does not have. It returns nil when the fetch
+// failed for another reason, or left out objects other than the missing ones.
+func missingOnSource(git *common.GitCommand, pointers []common.LFSPointer, output []byte) []common.MissingObject {
+    oids := common.ParseMissingObjects(output)
+    if len(oids) == 0 {
+        return nil
+    }
+
+    missing := make(map[string]bool)
+    for _, oid := range oids {
+        missing[oid] = true
+    }
+    for _, pointer := range pointers {
+        if !missing[pointer.OID] && !common.HasLFSObject(git.Dir, pointer.OID) {
+            return nil
+        }
+    }
+
+    return common.DescribeMissingObjects(git, oids, pointers)
+}
+
+// reuseSharedObjects links the objects of pointers from the shared store, so
+// that git-lfs only downloads the missing ones, and returns the number and
+// size of the objects linked. Failures only cost the download they would have
+// saved.
+func reuseSharedObjects(log *slog.Logger, git *common.GitCommand, objects *common.LFSStore, pointers []common.LFSPointer) (int, int64) {
+    if objects == nil {
+        return 0, 0
+    }
+
+    oids := make([]string, 0, len(pointers))
+    for _, pointer := range pointers {
+        oids = append(oids, pointer.OID)
+    }
+    linked, size, err := objects.LinkInto(git.Dir, oids)
+    if err != nil {
+        log.Warn("failed to reuse objects from the shared LFS store", "error", err)
+    }
+    if linked > 0 {
+        log.Info("reused objects from the shared LFS store", "objects", linked, "bytes", common.FormatBytes(size))
+    }
+    return linked, size
+}
+
+// storeSharedObjects adds the objects fetched for the repository to the
+// shared store
+func storeSharedObjects(log *slog.Logger, objects *common.LFSStore, repoPath string) {
+    added, err := objects.Add(repoPath)
+    if err != nil {
+        log.Warn("failed to add objects to the shared LFS store", "error", err)
+    }
+    if added > 0 {
+        log.Debug("added objects to the shared LFS store", "objects", added)
+    }
+}
@@ -0,0 +1,131 @@
+package pull
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// writeObject stores content as an LFS object below objectsDir
+func writeObject(t *testing.T, objectsDir, oid, content string) {
+	t.Helper()
+	path := filepath.Join(objectsDir, oid[0:2], oid[2:4], oid)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReuseSharedObjects(t *testing.T) {
+	shared, missing, present := strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)
+	pointers := []common.LFSPointer{{OID: shared}, {OID: missing}, {OID: present}}
+
+	// The store has the first object, the repository already has the last
+	workDir := t.TempDir()
+	dir := filepath.Join(workDir, "repo")
+	store, err := common.OpenLFSStore(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeDir := filepath.Join(workDir, common.LFSStoreDirName, "objects")
+	writeObject(t, storeDir, shared, "12345")
+	writeObject(t, storeDir, present, "12345")
+	writeObject(t, common.LFSObjectsDir(dir), present, "12345")
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	git := common.NewGitCommand(context.Background(), "repo", dir, nil)
+	if objects, size := reuseSharedObjects(log, git, store, pointers); objects != 1 || size != 5 {
+		t.Errorf("reuseSharedObjects() = %d, %d, want 1, 5", objects, size)
+	}
+	if !common.HasLFSObject(dir, shared) || common.HasLFSObject(dir, missing) {
+		t.Error("reuseSharedObjects() linked the wrong objects")
+	}
+	if objects, size := reuseSharedObjects(log, git, nil, pointers); objects != 0 || size != 0 {
+		t.Errorf("reuseSharedObjects() without store = %d, %d", objects, size)
+	}
+}
+
+func TestChunkPointers(t *testing.T) {
+	pointers := make([]common.LFSPointer, 5)
+	for i := range pointers {
+		pointers[i] = common.LFSPointer{OID: fmt.Sprint(i), Size: int64(i+1) * 10}
+	}
+	tests := []struct {
+		maxCount int
+		maxBytes int64
+		want     []int
+	}{
+		{2, 1000, []int{2, 2, 1}},
+		{100, 60, []int{3, 2}}, // 10+20+30 bytes reach the limit, then 40+50
+		{100, 1000, []int{5}},
+	}
+	for _, tt := range tests {
+		chunks := chunkPointers(pointers, tt.maxCount, tt.maxBytes)
+		var got []int
+		for _, chunk := range chunks {
+			got = append(got, len(chunk))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("chunkPointers(%d, %d) sizes = %v, want %v", tt.maxCount, tt.maxBytes, got, tt.want)
+		}
+	}
+}
+
+func TestPointerCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, output)
+		}
+	}
+	run("init", "-q")
+	for i, oid := range []string{strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)} {
+		pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, i+1)
+		path := filepath.Join(dir, fmt.Sprintf("dir%d", i), "file.bin")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(pointer), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("add", "-A")
+	run("commit", "-q", "-m", "pointers")
+
+	git := common.NewGitCommand(context.Background(), "repo", dir, nil)
+	pointers, err := common.ListLFSPointers(git)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// git-lfs fetches the objects of the pointers in the tree of the commit,
+	// which holds those of the chunk and no others
+	commit, err := pointerCommit(git, pointers[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := common.ListLFSPointers(git, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 2 || fetched[0].OID != pointers[1].OID || fetched[1].OID != pointers[2].OID ||
+		fetched[0].Path != pointers[1].OID {
+		t.Errorf("pointers of the commit = %+v, want those of %+v", fetched, pointers[1:])
+	}
+}
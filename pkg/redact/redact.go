@@ -0,0 +1,94 @@
+package redact
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const mask = "****"
+
+// Shorter values are not masked, they would garble unrelated output
+const minSecretLength = 8
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+
+	patterns = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		// Authorization headers, keeping the scheme
+		{regexp.MustCompile(`(?i)(authorization:\s*(?:basic|bearer|token|remoteauth)?\s*)[^\s"']+`), "${1}" + mask},
+		// Credentials embedded in URLs
+		{regexp.MustCompile(`(?i)([a-z][a-z0-9+.-]*://)[^/\s:@]+(?::[^/\s@]*)?@`), "${1}" + mask + "@"},
+		// Signatures and tokens in signed URLs (S3, Azure, GCS, CloudFront)
+		{regexp.MustCompile(`(?i)([?&](?:x-amz-signature|x-amz-credential|x-amz-security-token|x-goog-signature|x-goog-credential|signature|sig|token|access_token|jwt|policy|key-pair-id)=)[^&\s"']+`), "${1}" + mask},
+		// GitHub tokens, including the ghs_ installation tokens derived from App keys
+		{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`), mask},
+		// Private keys, such as GitHub App keys
+		{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), mask},
+	}
+)
+
+// Setting names ending in one of these hold secrets
+var secretSuffixes = []string{"TOKEN", "PRIVATE_KEY", "PRIVATE-KEY", "SECRET", "PASSWORD"}
+
+// IsSecretKey reports whether a setting or flag name holds a secret
+func IsSecretKey(name string) bool {
+	name = strings.ToUpper(name)
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSecret registers a value that must never appear in output
+func AddSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Secrets also show up URL-encoded, e.g. in remote URLs
+	for _, value := range []string{secret, url.QueryEscape(secret)} {
+		if !slices.Contains(secrets, value) {
+			secrets = append(secrets, value)
+		}
+	}
+
+	// Replace longer secrets first so one containing another is fully masked
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// String masks registered secrets, Authorization headers, URL credentials
+// and signed URL parameters
+func String(s string) string {
+	mu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	mu.RUnlock()
+
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// Error returns the redacted text of an error, or an empty string for nil
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return String(err.Error())
+}
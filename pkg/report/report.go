@@ -0,0 +1,419 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/pterm/pterm"
+)
+
+// Supported report formats
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// Report is a machine-readable record of a pull, sync or migrate run
+type Report struct {
+	Command      string    `json:"command"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	Summary      Summary   `json:"summary"`
+	Repositories []Entry   `json:"repositories"`
+}
+
+// Summary holds the totals of a run. Repositories are counted once, failed
+// when any of their phases failed, skipped when all of them were skipped and
+// succeeded otherwise. Objects and bytes are those transferred by all phases.
+type Summary struct {
+	Total            int            `json:"total"`
+	Succeeded        int            `json:"succeeded"`
+	Failed           int            `json:"failed"`
+	Skipped          int            `json:"skipped"`
+	Objects          int            `json:"objects"`
+	Bytes            int64          `json:"bytes"`
+	MissingObjects   int            `json:"missing_objects"`
+	OversizedObjects int            `json:"oversized_objects"`
+	CorruptObjects   int            `json:"corrupt_objects"`
+	DurationSeconds  float64        `json:"duration_seconds"`
+	Phases           []PhaseSummary `json:"phases,omitempty"`
+}
+
+// PhaseSummary holds the totals of one phase of a run with several phases
+type PhaseSummary struct {
+	Phase     string `json:"phase"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Skipped   int    `json:"skipped"`
+	Objects   int    `json:"objects"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Entry is the outcome of one phase for one repository
+type Entry struct {
+	Repository      string   `json:"repository"`
+	Phase           string   `json:"phase"`
+	Mode            string   `json:"mode"`
+	Status          string   `json:"status"`
+	StartedAt       string   `json:"started_at"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	Objects         int      `json:"objects"`
+	Bytes           int64    `json:"bytes"`
+	Refs            int      `json:"refs"`
+	Error           string   `json:"error,omitempty"`
+	ErrorCategory   string   `json:"error_category,omitempty"`
+	Events          []string `json:"events,omitempty"`
+
+	// Objects missing on source in pull, or skipped by an incomplete push
+	MissingObjects []common.MissingObject `json:"missing_objects,omitempty"`
+
+	// Objects larger than the target accepts, found before sync pushes
+	OversizedObjects []common.OversizedObject `json:"oversized_objects,omitempty"`
+
+	// Objects quarantined because their content does not match their OID
+	CorruptObjects []common.CorruptObject `json:"corrupt_objects,omitempty"`
+}
+
+// New builds a report from the stats of one or more phases of a run
+func New(command string, stats ...*common.ProcessStats) *Report {
+	r := &Report{
+		Command:      command,
+		GeneratedAt:  time.Now().UTC(),
+		Repositories: []Entry{},
+	}
+
+	var start time.Time
+	repoStatus := make(map[string]string)
+	var repos []string
+	var phases []PhaseSummary
+	for _, s := range stats {
+		if start.IsZero() || s.StartTime.Before(start) {
+			start = s.StartTime
+		}
+
+		events := make(map[string][]string)
+		for _, event := range s.Events {
+			events[event.Repository] = append(events[event.Repository],
+				fmt.Sprintf("%s %s", event.Time.UTC().Format(time.RFC3339), redact.String(event.Message)))
+		}
+
+		for _, result := range s.Results {
+			r.Repositories = append(r.Repositories, Entry{
+				Repository:       result.Repository.Name,
+				Phase:            result.Phase,
+				Mode:             result.Mode,
+				Status:           result.Status,
+				StartedAt:        result.StartTime.UTC().Format(time.RFC3339),
+				DurationSeconds:  result.Duration.Round(time.Millisecond).Seconds(),
+				Objects:          result.Objects,
+				Bytes:            result.Bytes,
+				Refs:             result.Refs,
+				Error:            redact.String(result.Error),
+				ErrorCategory:    result.Category,
+				Events:           events[result.Repository.Name],
+				MissingObjects:   result.Missing,
+				OversizedObjects: result.Oversized,
+				CorruptObjects:   result.Corrupt,
+			})
+
+			r.Summary.Objects += result.Objects
+			r.Summary.Bytes += result.Bytes
+			r.Summary.MissingObjects += len(result.Missing)
+			r.Summary.OversizedObjects += len(result.Oversized)
+			r.Summary.CorruptObjects += len(result.Corrupt)
+
+			name := result.Repository.Name
+			status, seen := repoStatus[name]
+			if !seen {
+				repos = append(repos, name)
+			}
+			repoStatus[name] = mergeStatus(status, result.Status)
+
+			i := slices.IndexFunc(phases, func(p PhaseSummary) bool { return p.Phase == result.Phase })
+			if i < 0 {
+				phases = append(phases, PhaseSummary{Phase: result.Phase})
+				i = len(phases) - 1
+			}
+			phase := &phases[i]
+			phase.Objects += result.Objects
+			phase.Bytes += result.Bytes
+			switch result.Status {
+			case common.StatusSuccess:
+				phase.Succeeded++
+			case common.StatusFailed:
+				phase.Failed++
+			case common.StatusSkipped:
+				phase.Skipped++
+			}
+		}
+	}
+
+	r.Summary.Total = len(repos)
+	for _, name := range repos {
+		switch repoStatus[name] {
+		case common.StatusSuccess:
+			r.Summary.Succeeded++
+		case common.StatusFailed:
+			r.Summary.Failed++
+		case common.StatusSkipped:
+			r.Summary.Skipped++
+		}
+	}
+	if len(phases) > 1 {
+		r.Summary.Phases = phases
+	}
+	if !start.IsZero() {
+		r.Summary.DurationSeconds = time.Since(start).Round(time.Second).Seconds()
+	}
+
+	return r
+}
+
+// mergeStatus combines the status of a repository so far with the status of
+// another of its phases
+func mergeStatus(current, next string) string {
+	switch {
+	case current == "":
+		return next
+	case current == common.StatusFailed || next == common.StatusFailed:
+		return common.StatusFailed
+	case current == common.StatusSuccess || next == common.StatusSuccess:
+		return common.StatusSuccess
+	}
+	return current
+}
+
+// Save writes the report for a run when a report path is configured
+func Save(path, format, command string, stats ...*common.ProcessStats) {
+	if path == "" {
+		return
+	}
+
+	if err := New(command, stats...).Write(path, format); err != nil {
+		pterm.Warning.Printf("Failed to write report: %v\n", err)
+		return
+	}
+	pterm.Info.Printf("Report written to %s\n", path)
+}
+
+// Write saves the report in the given format, or the format matching the file
+// extension when format is empty
+func (r *Report) Write(path, format string) error {
+	format, err := resolveFormat(path, format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer file.Close()
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(r)
+	case FormatCSV:
+		err = r.writeCSV(file)
+	case FormatMarkdown:
+		_, err = file.WriteString(r.markdown())
+	}
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+
+	return nil
+}
+
+func resolveFormat(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	switch strings.ToLower(format) {
+	case "json", "":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unsupported report format %q, use json, csv or markdown", format)
+}
+
+func (r *Report) writeCSV(file *os.File) error {
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write([]string{
+		"Repository", "Phase", "Mode", "Status", "StartedAt", "DurationSeconds",
+		"Objects", "Bytes", "Refs", "Error", "ErrorCategory", "Events", "MissingObjects", "OversizedObjects", "CorruptObjects",
+	}); err != nil {
+		return err
+	}
+
+	for _, entry := range r.Repositories {
+		if err := writer.Write([]string{
+			entry.Repository,
+			entry.Phase,
+			entry.Mode,
+			entry.Status,
+			entry.StartedAt,
+			strconv.FormatFloat(entry.DurationSeconds, 'f', 3, 64),
+			strconv.Itoa(entry.Objects),
+			strconv.FormatInt(entry.Bytes, 10),
+			strconv.Itoa(entry.Refs),
+			entry.Error,
+			entry.ErrorCategory,
+			strings.Join(entry.Events, "; "),
+			formatMissingObjects(entry.MissingObjects),
+			formatOversizedObjects(entry.OversizedObjects),
+			formatCorruptObjects(entry.CorruptObjects),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *Report) markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# LFS %s report\n\n", r.Command)
+	fmt.Fprintf(&b, "Generated at %s\n\n", r.GeneratedAt.Format(time.RFC3339))
+
+	fmt.Fprintf(&b, "## Summary\n\n")
+	fmt.Fprintf(&b, "| Total | Succeeded | Failed | Skipped | Objects | Size | Duration |\n")
+	fmt.Fprintf(&b, "| ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %s | %v |\n\n",
+		r.Summary.Total, r.Summary.Succeeded, r.Summary.Failed, r.Summary.Skipped,
+		r.Summary.Objects, common.FormatBytes(r.Summary.Bytes),
+		time.Duration(r.Summary.DurationSeconds)*time.Second)
+
+	if len(r.Summary.Phases) > 0 {
+		fmt.Fprintf(&b, "| Phase | Succeeded | Failed | Skipped | Objects | Size |\n")
+		fmt.Fprintf(&b, "| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, phase := range r.Summary.Phases {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %s |\n",
+				phase.Phase, phase.Succeeded, phase.Failed, phase.Skipped, phase.Objects, common.FormatBytes(phase.Bytes))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	fmt.Fprintf(&b, "## Repositories\n\n")
+	fmt.Fprintf(&b, "| Repository | Phase | Mode | Status | Duration | Objects | Size | Refs | Error category | Error |\n")
+	fmt.Fprintf(&b, "| --- | --- | --- | --- | ---: | ---: | ---: | ---: | --- | --- |\n")
+	for _, entry := range r.Repositories {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %.1fs | %d | %s | %d | %s | %s |\n",
+			markdownCell(entry.Repository), entry.Phase, entry.Mode, entry.Status, entry.DurationSeconds,
+			entry.Objects, common.FormatBytes(entry.Bytes), entry.Refs, entry.ErrorCategory, markdownCell(entry.Error))
+	}
+
+	var events []string
+	for _, entry := range r.Repositories {
+		for _, event := range entry.Events {
+			events = append(events, fmt.Sprintf("- `%s` %s", entry.Repository, event))
+		}
+	}
+	if len(events) > 0 {
+		fmt.Fprintf(&b, "\n## Repository changes\n\n%s\n", strings.Join(events, "\n"))
+	}
+
+	if r.Summary.MissingObjects > 0 {
+		fmt.Fprintf(&b, "\n## Missing objects\n\n")
+		fmt.Fprintf(&b, "| Repository | Phase | OID | Path | Commit | Refs |\n")
+		fmt.Fprintf(&b, "| --- | --- | --- | --- | --- | --- |\n")
+		for _, entry := range r.Repositories {
+			for _, object := range entry.MissingObjects {
+				fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s | %s |\n",
+					markdownCell(entry.Repository), entry.Phase, object.OID, markdownCell(object.Path),
+					object.Commit, markdownCell(strings.Join(object.Refs, " ")))
+			}
+		}
+	}
+
+	if r.Summary.OversizedObjects > 0 {
+		fmt.Fprintf(&b, "\n## Oversized objects\n\n")
+		fmt.Fprintf(&b, "| Repository | OID | Path | Size |\n")
+		fmt.Fprintf(&b, "| --- | --- | --- | ---: |\n")
+		for _, entry := range r.Repositories {
+			for _, object := range entry.OversizedObjects {
+				fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n",
+					markdownCell(entry.Repository), object.OID, markdownCell(object.Path), common.FormatBytes(object.Size))
+			}
+		}
+	}
+
+	if r.Summary.CorruptObjects > 0 {
+		fmt.Fprintf(&b, "\n## Corrupt objects\n\n")
+		fmt.Fprintf(&b, "| Repository | Phase | OID | Size | Content SHA-256 |\n")
+		fmt.Fprintf(&b, "| --- | --- | --- | ---: | --- |\n")
+		for _, entry := range r.Repositories {
+			for _, object := range entry.CorruptObjects {
+				fmt.Fprintf(&b, "| %s | %s | `%s` | %s | `%s` |\n",
+					markdownCell(entry.Repository), entry.Phase, object.OID, common.FormatBytes(object.Size), object.SHA256)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// formatMissingObjects renders missing objects as "<oid> <path>@<commit>"
+// items for a single CSV cell
+func formatMissingObjects(objects []common.MissingObject) string {
+	items := make([]string, 0, len(objects))
+	for _, object := range objects {
+		item := object.OID
+		if object.Path != "" {
+			item += " " + object.Path
+		}
+		if object.Commit != "" {
+			item += "@" + object.Commit
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, "; ")
+}
+
+// formatOversizedObjects renders oversized objects as "<oid> <path> (<size>)"
+// items for a single CSV cell
+func formatOversizedObjects(objects []common.OversizedObject) string {
+	items := make([]string, 0, len(objects))
+	for _, object := range objects {
+		item := object.OID
+		if object.Path != "" {
+			item += " " + object.Path
+		}
+		items = append(items, fmt.Sprintf("%s (%d)", item, object.Size))
+	}
+	return strings.Join(items, "; ")
+}
+
+// formatCorruptObjects renders corrupt objects as "<oid> (<size>)" items for a
+// single CSV cell
+func formatCorruptObjects(objects []common.CorruptObject) string {
+	items := make([]string, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, fmt.Sprintf("%s (%d)", object.OID, object.Size))
+	}
+	return strings.Join(items, "; ")
+}
+
+// markdownCell keeps multi-line error output inside a single table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
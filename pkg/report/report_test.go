@@ -0,0 +1,86 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+)
+
+func TestNewSummary(t *testing.T) {
+	result := func(repo, phase, status string, objects int) common.RepoResult {
+		return common.RepoResult{Repository: inventory.Repository{Name: repo}, Phase: phase, Status: status, Objects: objects, Bytes: int64(objects) * 10}
+	}
+
+	tests := []struct {
+		name    string
+		results [][]common.RepoResult
+		want    Summary
+	}{
+		{
+			"single phase",
+			[][]common.RepoResult{{
+				result("a", "pull", common.StatusSuccess, 2),
+				result("b", "pull", common.StatusFailed, 0),
+				result("c", "pull", common.StatusSkipped, 0),
+			}},
+			Summary{Total: 3, Succeeded: 1, Failed: 1, Skipped: 1, Objects: 2, Bytes: 20},
+		},
+		{
+			"migrate counts each repository once",
+			[][]common.RepoResult{
+				{
+					result("a", "pull", common.StatusSuccess, 2),
+					result("b", "pull", common.StatusSuccess, 1),
+					result("c", "pull", common.StatusSkipped, 0),
+					result("d", "pull", common.StatusFailed, 0),
+				},
+				{
+					result("a", "sync", common.StatusSuccess, 2),
+					result("b", "sync", common.StatusFailed, 0),
+					result("c", "sync", common.StatusSkipped, 0),
+				},
+			},
+			Summary{
+				Total: 4, Succeeded: 1, Failed: 2, Skipped: 1, Objects: 5, Bytes: 50,
+				Phases: []PhaseSummary{
+					{Phase: "pull", Succeeded: 2, Failed: 1, Skipped: 1, Objects: 3, Bytes: 30},
+					{Phase: "sync", Succeeded: 1, Failed: 1, Skipped: 1, Objects: 2, Bytes: 20},
+				},
+			},
+		},
+		{
+			"resumed repository succeeds",
+			[][]common.RepoResult{
+				{result("a", "pull", common.StatusSkipped, 0)},
+				{result("a", "sync", common.StatusSuccess, 2)},
+			},
+			Summary{
+				Total: 1, Succeeded: 1, Objects: 2, Bytes: 20,
+				Phases: []PhaseSummary{
+					{Phase: "pull", Skipped: 1},
+					{Phase: "sync", Succeeded: 1, Objects: 2, Bytes: 20},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats []*common.ProcessStats
+			for _, results := range tt.results {
+				s := common.NewProcessStats()
+				for _, r := range results {
+					s.RecordResult(r)
+				}
+				stats = append(stats, s)
+			}
+
+			got := New("migrate", stats...).Summary
+			got.DurationSeconds = 0
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+)
+
+// FileName is the name of the state file kept in the working directory
+const FileName = ".ghmlfs-state.json"
+
+const (
+	PhasePull = "pull"
+	PhaseSync = "sync"
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Phase holds the outcome of one phase (pull, sync) for a repository
+type Phase struct {
+	Status     Status    `json:"status"`
+	Remote     string    `json:"remote,omitempty"` // repository the phase fetched from or pushed to
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	RefCount   int       `json:"ref_count"`
+	RefsHash   string    `json:"refs_hash,omitempty"` // SHA-256 of the sorted ref list, see HashRefs
+	Objects    int       `json:"objects"`
+	Bytes      int64     `json:"bytes"`
+	LastError  string    `json:"last_error,omitempty"`
+
+	// Refs were stored in full by older versions, and are folded into
+	// RefCount and RefsHash when the state file is read
+	Refs map[string]string `json:"refs,omitempty"`
+
+	// Objects referenced in the repository that the source could not serve
+	Missing []common.MissingObject `json:"missing_objects,omitempty"`
+}
+
+// Repository holds the state of every phase run for a repository
+type Repository struct {
+	Phases map[string]*Phase `json:"phases"`
+}
+
+// Result is what a phase reports when it completes
+type Result struct {
+	Refs    map[string]string
+	Objects int
+	Bytes   int64
+	Missing []common.MissingObject
+}
+
+// HashRefs returns the SHA-256 of the "<sha> <ref>" lines of refs, sorted by
+// ref, which changes whenever any ref is added, removed or moved
+func HashRefs(refs map[string]string) string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s %s\n", refs[name], name)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// CollectResult gathers the refs and local LFS objects of a repository in the working directory
+func CollectResult(ctx context.Context, repoName, repoPath string) (Result, error) {
+	refs, err := common.ListRefs(common.NewGitCommand(ctx, repoName, repoPath, nil))
+	if err != nil {
+		return Result{}, err
+	}
+
+	objects, bytes, err := common.LFSObjectStats(repoPath)
+	if err != nil {
+		return Result{Refs: refs}, err
+	}
+
+	return Result{Refs: refs, Objects: objects, Bytes: bytes}, nil
+}
+
+// Store persists per-repository migration state to a JSON file in the working directory
+type Store struct {
+	Repositories map[string]*Repository `json:"repositories"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// Open loads the state file from workDir, starting empty when it does not exist yet
+func Open(workDir string) (*Store, error) {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %w", err)
+	}
+
+	store := &Store{
+		Repositories: make(map[string]*Repository),
+		path:         filepath.Join(workDir, FileName),
+	}
+
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", store.path, err)
+	}
+	if store.Repositories == nil {
+		store.Repositories = make(map[string]*Repository)
+	}
+	for _, r := range store.Repositories {
+		for _, p := range r.Phases {
+			if p.Refs != nil {
+				p.RefCount, p.RefsHash, p.Refs = len(p.Refs), HashRefs(p.Refs), nil
+			}
+		}
+	}
+
+	return store, nil
+}
+
+// Path returns the location of the state file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Start marks a phase as running for a repository against a remote
+func (s *Store) Start(repo, phase, remote string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase, true)
+	p.Status = StatusRunning
+	p.Remote = remote
+	p.StartedAt = time.Now()
+	p.FinishedAt = time.Time{}
+
+	return s.save()
+}
+
+// Complete marks a phase as completed and records its result
+func (s *Store) Complete(repo, phase string, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase, true)
+	p.Status = StatusCompleted
+	p.FinishedAt = time.Now()
+	p.RefCount = len(result.Refs)
+	p.RefsHash = HashRefs(result.Refs)
+	p.Objects = result.Objects
+	p.Bytes = result.Bytes
+	p.Missing = result.Missing
+	p.LastError = ""
+
+	return s.save()
+}
+
+// Fail marks a phase as failed and records the error
+func (s *Store) Fail(repo, phase string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase, true)
+	p.Status = StatusFailed
+	p.FinishedAt = time.Now()
+	if err != nil {
+		p.LastError = redact.Error(err)
+	}
+
+	return s.save()
+}
+
+// IsCompleted reports whether a phase has completed for a repository against
+// a remote. A phase completed against another remote, such as a sync to
+// another target organization, is not. Phases recorded before remotes were
+// stored match any remote.
+func (s *Store) IsCompleted(repo, phase, remote string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase, false)
+	return p != nil && p.Status == StatusCompleted && (p.Remote == "" || p.Remote == remote)
+}
+
+// Phase returns a copy of the phase state for a repository
+func (s *Store) Phase(repo, phase string) (Phase, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase, false)
+	if p == nil {
+		return Phase{}, false
+	}
+	return *p, true
+}
+
+// RepositoryNames returns the names of all repositories in the store, sorted
+func (s *Store) RepositoryNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.Repositories))
+	for name := range s.Repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Store) phase(repo, phase string, create bool) *Phase {
+	r, ok := s.Repositories[repo]
+	if !ok {
+		if !create {
+			return nil
+		}
+		r = &Repository{Phases: make(map[string]*Phase)}
+		s.Repositories[repo] = r
+	}
+
+	p, ok := r.Phases[phase]
+	if !ok {
+		if !create {
+			return nil
+		}
+		p = &Phase{}
+		r.Phases[phase] = p
+	}
+	return p
+}
+
+// save writes the state file atomically; callers must hold s.mu
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
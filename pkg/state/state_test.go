@@ -0,0 +1,170 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+const (
+	target      = "https://github.com/mona-emu/repo.git"
+	otherTarget = "https://github.example.com/mona-emu/repo.git"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.IsCompleted("repo", PhaseSync, target) {
+		t.Fatal("empty store reports a completed phase")
+	}
+
+	if err := store.Start("repo", PhaseSync, target); err != nil {
+		t.Fatal(err)
+	}
+	if store.IsCompleted("repo", PhaseSync, target) {
+		t.Fatal("running phase reported as completed")
+	}
+
+	if err := store.Fail("repo", PhaseSync, errors.New("push failed")); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := store.Phase("repo", PhaseSync); p.Status != StatusFailed || p.LastError != "push failed" {
+		t.Fatalf("failed phase = %+v", p)
+	}
+
+	missing := []common.MissingObject{{OID: "aaaa", Path: "a.bin"}}
+	if err := store.Complete("repo", PhaseSync, Result{Objects: 2, Bytes: 10, Missing: missing}); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := store.Phase("repo", PhaseSync)
+	if !ok || p.Status != StatusCompleted || p.LastError != "" || p.Objects != 2 || p.Bytes != 10 || len(p.Missing) != 1 {
+		t.Fatalf("completed phase = %+v", p)
+	}
+	if !store.IsCompleted("repo", PhaseSync, target) {
+		t.Error("completed phase not reported as completed")
+	}
+	if store.IsCompleted("repo", PhasePull, target) {
+		t.Error("other phase reported as completed")
+	}
+}
+
+func TestStoreRemoteMismatch(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start("repo", PhaseSync, target); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete("repo", PhaseSync, Result{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.IsCompleted("repo", PhaseSync, otherTarget) {
+		t.Error("sync to another target reported as completed")
+	}
+
+	// Starting against the other target replaces the recorded one
+	if err := store.Start("repo", PhaseSync, otherTarget); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete("repo", PhaseSync, Result{}); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsCompleted("repo", PhaseSync, otherTarget) || store.IsCompleted("repo", PhaseSync, target) {
+		t.Error("completed target not replaced")
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	workDir := t.TempDir()
+	store, err := Open(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start("repo", PhasePull, "https://github.com/mona/repo.git"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete("repo", PhasePull, Result{Refs: map[string]string{"refs/heads/main": "abc"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.IsCompleted("repo", PhasePull, "https://github.com/mona/repo.git") {
+		t.Error("completed phase lost on reopen")
+	}
+	if p, _ := reopened.Phase("repo", PhasePull); p.RefCount != 1 || p.RefsHash != HashRefs(map[string]string{"refs/heads/main": "abc"}) || p.Refs != nil {
+		t.Errorf("refs = %d, %q, %v", p.RefCount, p.RefsHash, p.Refs)
+	}
+	if names := reopened.RepositoryNames(); len(names) != 1 || names[0] != "repo" {
+		t.Errorf("RepositoryNames() = %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, FileName+".tmp")); !os.IsNotExist(err) {
+		t.Error("temporary state file left behind")
+	}
+}
+
+func TestStoreLegacyPhaseMatchesAnyRemote(t *testing.T) {
+	workDir := t.TempDir()
+	legacy := `{"repositories":{"repo":{"phases":{"sync":{"status":"completed","started_at":"2024-11-20T10:00:00Z","finished_at":"2024-11-20T10:05:00Z","objects":1,"bytes":1}}}}}`
+	if err := os.WriteFile(filepath.Join(workDir, FileName), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsCompleted("repo", PhaseSync, target) {
+		t.Error("phase recorded without a remote not reported as completed")
+	}
+}
+
+func TestHashRefs(t *testing.T) {
+	refs := map[string]string{"refs/heads/main": "abc", "refs/tags/v1": "def"}
+	if HashRefs(refs) != HashRefs(map[string]string{"refs/tags/v1": "def", "refs/heads/main": "abc"}) {
+		t.Error("hash depends on map order")
+	}
+	if HashRefs(refs) == HashRefs(map[string]string{"refs/heads/main": "abc", "refs/tags/v1": "123"}) {
+		t.Error("moved ref does not change the hash")
+	}
+	if HashRefs(refs) == HashRefs(map[string]string{"refs/heads/main": "abc"}) {
+		t.Error("removed ref does not change the hash")
+	}
+}
+
+func TestStoreLegacyRefs(t *testing.T) {
+	workDir := t.TempDir()
+	legacy := `{"repositories":{"repo":{"phases":{"pull":{"status":"completed","refs":{"refs/heads/main":"abc","refs/pull/1/head":"def"}}}}}}`
+	if err := os.WriteFile(filepath.Join(workDir, FileName), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := store.Phase("repo", PhasePull)
+	if p.RefCount != 2 || p.RefsHash != HashRefs(map[string]string{"refs/heads/main": "abc", "refs/pull/1/head": "def"}) || p.Refs != nil {
+		t.Errorf("legacy refs = %d, %q, %v", p.RefCount, p.RefsHash, p.Refs)
+	}
+}
+
+func TestOpenInvalidStateFile(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, FileName), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(workDir); err == nil {
+		t.Error("Open() accepted an invalid state file")
+	}
+}
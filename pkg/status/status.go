@@ -0,0 +1,76 @@
+package status
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// ShowStatus prints the migration state recorded in the working directory
+func ShowStatus() error {
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+
+	if _, err := os.Stat(filepath.Join(workDir, state.FileName)); os.IsNotExist(err) {
+		pterm.Info.Printf("No migration state found in %s\n", workDir)
+		return nil
+	}
+
+	store, err := state.Open(workDir)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[state.Status]int)
+	data := pterm.TableData{
+		{"Repository", "Phase", "Status", "Started", "Finished", "Refs", "Objects", "Size", "Missing", "Last error"},
+	}
+
+	for _, repo := range store.RepositoryNames() {
+		for _, phaseName := range []string{state.PhasePull, state.PhaseSync} {
+			phase, ok := store.Phase(repo, phaseName)
+			if !ok {
+				continue
+			}
+			counts[phase.Status]++
+
+			data = append(data, []string{
+				repo,
+				phaseName,
+				string(phase.Status),
+				formatTime(phase.StartedAt),
+				formatTime(phase.FinishedAt),
+				fmt.Sprintf("%d", phase.RefCount),
+				fmt.Sprintf("%d", phase.Objects),
+				common.FormatBytes(phase.Bytes),
+				fmt.Sprintf("%d", len(phase.Missing)),
+				redact.String(phase.LastError),
+			})
+		}
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+		return fmt.Errorf("failed to render status table: %w", err)
+	}
+
+	fmt.Printf("\n📊 Status Summary:\n")
+	fmt.Printf("✅ Completed: %d\n", counts[state.StatusCompleted])
+	fmt.Printf("🔄 Running or interrupted: %d\n", counts[state.StatusRunning])
+	fmt.Printf("❌ Failed: %d\n", counts[state.StatusFailed])
+	fmt.Printf("📁 State file: %s\n", store.Path())
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/logger"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+)
+
+// withUnarchivedTarget runs push against the target repository. When allowed
+// to, it first checks whether the repository is archived and unarchives it.
+// An unarchived repository is always archived again afterwards, even when push
+// fails or is interrupted. Otherwise the API is not called and a push rejected
+// by an archived repository fails with a hint.
+func withUnarchivedTarget(ctx context.Context, repoName, targetOrg, token, hostname string, allowUnarchive bool, stats *common.ProcessStats, push func() error) (err error) {
+	fullName := fmt.Sprintf("%s/%s", targetOrg, repoName)
+	log := logger.ForRepo(repoName, state.PhaseSync)
+
+	if !allowUnarchive {
+		if err := push(); err != nil {
+			if common.ClassifyError(err) == common.CategoryArchived {
+				return fmt.Errorf("target repository %s is archived, use --unarchive to allow syncing archived repositories: %w", fullName, err)
+			}
+			return err
+		}
+		return nil
+	}
+
+	hostname = common.GitHubAPIURL(hostname)
+	repo, err := api.GetRepository(ctx, targetOrg, repoName, token, hostname)
+	if err != nil {
+		return fmt.Errorf("failed to check target repository %s: %w", fullName, err)
+	}
+
+	if !repo.GetArchived() {
+		return push()
+	}
+
+	if err := api.SetRepositoryArchived(ctx, targetOrg, repoName, token, false, hostname); err != nil {
+		return fmt.Errorf("failed to unarchive target repository %s: %w", fullName, err)
+	}
+	stats.RecordEvent(repoName, "unarchived target repository %s", fullName)
+	log.Info("unarchived target repository", "target", fullName)
+
+	defer func() {
+		if archiveErr := api.SetRepositoryArchived(context.WithoutCancel(ctx), targetOrg, repoName, token, true, hostname); archiveErr != nil {
+			stats.RecordEvent(repoName, "failed to re-archive target repository %s: %v", fullName, archiveErr)
+			log.Error("failed to re-archive target repository", "target", fullName, "error", archiveErr)
+			err = errors.Join(err, fmt.Errorf("failed to re-archive target repository %s: %w", fullName, archiveErr))
+			return
+		}
+		stats.RecordEvent(repoName, "re-archived target repository %s", fullName)
+		log.Info("re-archived target repository", "target", fullName)
+	}()
+
+	return push()
+}
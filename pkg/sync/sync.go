@@ -1,84 +1,197 @@
 package sync
 
 import (
-    "encoding/csv"
+    "context"
     "fmt"
+    "log/slog"
     "os"
     "os/exec"
     "path/filepath"
+    "slices"
+    "strconv"
     "strings"
-    "bufio"
+    "time"
 
     "github.com/mona-actions/gh-migrate-lfs/pkg/common"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/logger"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/redact"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/report"
+    "github.com/mona-actions/gh-migrate-lfs/pkg/state"
+    "github.com/pterm/pterm"
     "github.com/spf13/viper"
 )
 
-type syncJob struct {
-    repoName  string
-    workDir   string
-    targetOrg string
+// When branch mode pushes the LFS objects of tags, always after the default branch
+const (
+    TagsBeforeBranches = "before-branches"
+    TagsAfterBranches  = "after-branches"
+    TagsNone           = "none"
+)
+
+// ParseTagOrder validates a tag order, using TagsBeforeBranches when empty
+func ParseTagOrder(order string) (string, error) {
+    switch order {
+    case "":
+        return TagsBeforeBranches, nil
+    case TagsBeforeBranches, TagsAfterBranches, TagsNone:
+        return order, nil
+    }
+    return "", fmt.Errorf("invalid tag-order %q, use %s, %s or %s", order, TagsBeforeBranches, TagsAfterBranches, TagsNone)
+}
+
+// How sync handles LFS objects larger than the target accepts
+const (
+    OversizedFail = "fail"
+    OversizedSkip = "skip"
+)
+
+// ParseOversizedPolicy validates an oversized object policy, using
+// OversizedFail when empty
+func ParseOversizedPolicy(policy string) (string, error) {
+    switch policy {
+    case "":
+        return OversizedFail, nil
+    case OversizedFail, OversizedSkip:
+        return policy, nil
+    }
+    return "", fmt.Errorf("invalid oversized-policy %q, use %s or %s", policy, OversizedFail, OversizedSkip)
+}
+
+// ParseMaxObjectSize parses the largest LFS object size the target accepts,
+// 0 when no limit is set
+func ParseMaxObjectSize(size string) (int64, error) {
+    if strings.TrimSpace(size) == "" {
+        return 0, nil
+    }
+    limit, err := common.ParseSize(size)
+    if err != nil {
+        return 0, fmt.Errorf("invalid max-object-size: %w", err)
+    }
+    return limit, nil
 }
 
-func SyncFromCSV() error {
+// Objects are pushed in chunks of at most this many objects or bytes, so that
+// an interrupted push only repeats the chunk in flight
+const (
+    pushChunkObjects = 100
+    pushChunkBytes   = 1 << 30
+)
+
+func SyncFromCSV(ctx context.Context) error {
     inputFile := viper.GetString("GHMLFS_FILE")
     workDir := viper.GetString("GHMLFS_WORK_DIR")
     targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
     token := viper.GetString("GHMLFS_TARGET_TOKEN")
     maxWorkers := viper.GetInt("GHMLFS_WORKERS")
     branchMode := viper.GetBool("GHMLFS_BRANCH_MODE")
+    hostname := viper.GetString("GHMLFS_TARGET_HOSTNAME")
+    allowUnarchive := viper.GetBool("GHMLFS_UNARCHIVE")
+    allowIncomplete := viper.GetBool("GHMLFS_ALLOW_INCOMPLETE_PUSH")
+    verify := !viper.GetBool("GHMLFS_SKIP_VERIFY")
+    force := viper.GetBool("GHMLFS_FORCE")
+
+    workers, err := common.NewConcurrency(maxWorkers, viper.GetBool("GHMLFS_ADAPTIVE_WORKERS"),
+        viper.GetInt("GHMLFS_MIN_WORKERS"), viper.GetInt("GHMLFS_MAX_WORKERS"))
+    if err != nil {
+        return err
+    }
+    if workers.Adaptive {
+        pterm.Info.Printf("Workers: %s\n", workers)
+    }
 
-	// Ensure at least 1 worker
-	if maxWorkers <= 0 {
-		maxWorkers = 1
-	}
+    refs, err := common.ParseRefFilter(viper.GetString("GHMLFS_INCLUDE_REFS"),
+        viper.GetString("GHMLFS_EXCLUDE_REFS"), viper.GetString("GHMLFS_REFS_SINCE"))
+    if err != nil {
+        return err
+    }
 
-    // Read CSV file
-    file, err := os.Open(inputFile)
+    tagOrder, err := ParseTagOrder(viper.GetString("GHMLFS_TAG_ORDER"))
     if err != nil {
-        return fmt.Errorf("error opening input file: %w", err)
+        return err
     }
-    defer file.Close()
 
-    reader := csv.NewReader(file)
-    // Skip header
-    if _, err := reader.Read(); err != nil {
-        return fmt.Errorf("error reading CSV header: %w", err)
+    maxObjectSize, err := ParseMaxObjectSize(viper.GetString("GHMLFS_MAX_OBJECT_SIZE"))
+    if err != nil {
+        return err
+    }
+    oversizedPolicy, err := ParseOversizedPolicy(viper.GetString("GHMLFS_OVERSIZED_POLICY"))
+    if err != nil {
+        return err
+    }
+    if maxObjectSize > 0 {
+        pterm.Info.Printf("Maximum LFS object size: %s (%s oversized objects)\n", common.FormatBytes(maxObjectSize), oversizedPolicy)
     }
 
-    // Create jobs channel and track unique repositories
-    jobs := make(chan syncJob)
-    seen := make(map[string]bool)
+    store, err := state.Open(workDir)
+    if err != nil {
+        return err
+    }
+
+    timeouts, err := common.ParseTimeouts(
+        viper.GetString("GHMLFS_REPO_TIMEOUT"),
+        viper.GetString("GHMLFS_OPERATION_TIMEOUT"),
+        viper.GetString("GHMLFS_STALL_TIMEOUT"),
+        viper.GetInt("GHMLFS_TIMEOUT_RETRIES"),
+    )
+    if err != nil {
+        return err
+    }
+    common.SetGitTimeouts(timeouts)
+
+    if viper.GetBool("GHMLFS_DEBUG") {
+        if err := common.EnableGitDebug(workDir); err != nil {
+            return err
+        }
+    }
+
+    // Re-run only the repositories of a previous failures file when given
+    if retryFile := viper.GetString("GHMLFS_RETRY_FAILED"); retryFile != "" {
+        pterm.Info.Printf("Retrying failed repositories from %s\n", retryFile)
+        inputFile = retryFile
+    }
+
+    // Read CSV file
+    repos, err := inventory.Read(inputFile)
+    if err != nil {
+        return err
+    }
+    repos = common.Schedule(repos, workDir)
 
     // Start goroutine to send jobs
+    jobs := make(chan inventory.Repository)
     go func() {
         defer close(jobs)
-        for {
-            record, err := reader.Read()
-            if err != nil {
-                break
-            }
-            repoName := record[0]
-            if seen[repoName] {
-                continue
-            }
-            seen[repoName] = true
-
-            jobs <- syncJob{
-                repoName:  repoName,
-                workDir:   workDir,
-                targetOrg: targetOrg,
-            }
+        for _, repo := range repos {
+            jobs <- repo
         }
     }()
 
     // Create and run worker pool
     stats := common.NewProcessStats()
-    err = common.WorkerPool(jobs, maxWorkers, stats, func(job syncJob) error {
-        if branchMode {
-            return SyncLFSContentBranchMode(job.repoName, job.workDir, job.targetOrg, token)
-        }
-        return SyncLFSContentMirrorMode(job.repoName, job.workDir, job.targetOrg, token)
-    })
+    syncer := &Syncer{
+        Token:           token,
+        WorkDir:         workDir,
+        TargetOrg:       targetOrg,
+        Hostname:        hostname,
+        BranchMode:      branchMode,
+        AllowUnarchive:  allowUnarchive,
+        Force:           force,
+        Refs:            refs,
+        TagOrder:        tagOrder,
+        AllowIncomplete: allowIncomplete,
+        MaxObjectSize:   maxObjectSize,
+        OversizedPolicy: oversizedPolicy,
+        Verify:          verify,
+        Timeouts:        timeouts,
+        Store:           store,
+        Stats:           stats,
+    }
+    err = common.WorkerPool(ctx, jobs, len(repos), workers, stats, syncer.Sync)
+
+    stats.SaveFailures(workDir, "sync")
+    stats.SaveMissingObjects(workDir, "sync")
+    report.Save(viper.GetString("GHMLFS_REPORT"), viper.GetString("GHMLFS_REPORT_FORMAT"), "sync", stats)
 
     // Print summary
     stats.PrintSummary(workDir)
@@ -91,179 +204,602 @@ func SyncFromCSV() error {
     return nil
 }
 
-func SyncLFSContentMirrorMode(repoName, workDir, targetOrg, token string) error {
-    repoPath := filepath.Join(workDir, repoName)
+// Syncer pushes the LFS objects of pulled repositories to the target
+// organization, recording each repository's progress in the state store
+type Syncer struct {
+    Token           string
+    WorkDir         string
+    TargetOrg       string
+    Hostname        string
+    BranchMode      bool
+    AllowUnarchive  bool
+    Force           bool
+    Refs            common.RefFilter // refs whose LFS objects are pushed
+    TagOrder        string           // when branch mode pushes tags, see ParseTagOrder
+    AllowIncomplete bool             // push the objects present locally, skipping the others
+    MaxObjectSize   int64            // largest LFS object the target accepts, 0 for no limit
+    OversizedPolicy string           // what to do with larger objects, see ParseOversizedPolicy
+    Verify          bool             // re-hash local objects before pushing, quarantining corrupt ones
+    Timeouts        common.Timeouts
+    Store           *state.Store
+    Stats           *common.ProcessStats
+}
+
+// Sync syncs a single repository, returning common.ErrSkipped when it was
+// already synced and Force is not set
+func (s *Syncer) Sync(ctx context.Context, repo inventory.Repository) error {
+    log := logger.ForRepo(repo.Name, state.PhaseSync)
+    result := common.RepoResult{
+        Repository: repo,
+        Phase:      state.PhaseSync,
+        Mode:       common.ModeName(s.BranchMode),
+        StartTime:  time.Now(),
+    }
+    defer func() {
+        result.Duration = time.Since(result.StartTime)
+        s.Stats.RecordResult(result)
+    }()
+
+    target := common.RepositoryURL(s.Hostname, s.TargetOrg, repo.Name)
+    if !s.Force && s.Store.IsCompleted(repo.Name, state.PhaseSync, target) {
+        log.Info("skipping repository, sync already completed (use --force to sync again)")
+        result.Status = common.StatusSkipped
+        return common.ErrSkipped
+    }
 
-    if err := configureGitAuth(token); err != nil {
+    if err := s.Store.Start(repo.Name, state.PhaseSync, target); err != nil {
+        log.Warn("failed to update state", "error", err)
+    }
+
+    // Forcing a sync pushes every object again
+    if s.Force {
+        if err := common.RemoveCheckpoint(s.WorkDir, repo.Name, state.PhaseSync); err != nil {
+            log.Warn("failed to remove checkpoint", "error", err)
+        }
+    }
+
+    ctx, task := common.StartTask(ctx, repo.Name, state.PhaseSync)
+    defer task.Done()
+
+    // Corrupt and oversized objects are found before anything is uploaded.
+    // Quarantined objects are then missing locally until pulled again.
+    corrupt, err := s.verifyObjects(ctx, log, repo.Name)
+    result.Corrupt = corrupt
+    var oversized []common.OversizedObject
+    if err == nil {
+        oversized, err = s.checkObjectSizes(ctx, log, repo.Name)
+    }
+    result.Oversized = oversized
+    if objects, bytes, err := common.LFSObjectStats(filepath.Join(s.WorkDir, repo.Name)); err == nil {
+        task.SetTotals(objects, bytes)
+    }
+    exclude := make(map[string]bool)
+    for _, object := range oversized {
+        exclude[object.OID] = true
+    }
+
+    var skipped []common.MissingObject
+    if err == nil {
+        err = s.Timeouts.Run(ctx, log, func(ctx context.Context) error {
+            return withUnarchivedTarget(ctx, repo.Name, s.TargetOrg, s.Token, s.Hostname, s.AllowUnarchive, s.Stats, func() error {
+                var err error
+                if s.BranchMode {
+                    skipped, err = SyncLFSContentBranchMode(ctx, repo.Name, s.WorkDir, s.TargetOrg, s.Hostname, s.Token, s.Refs, s.TagOrder, s.AllowIncomplete, exclude)
+                } else {
+                    skipped, err = SyncLFSContentMirrorMode(ctx, repo.Name, s.WorkDir, s.TargetOrg, s.Hostname, s.Token, s.Refs, s.AllowIncomplete, exclude)
+                }
+                return err
+            })
+        })
+    }
+    if err != nil {
+        result.SetError(err)
+        log.Error("sync failed", "error", err, "category", result.Category)
+        if stateErr := s.Store.Fail(repo.Name, state.PhaseSync, err); stateErr != nil {
+            log.Warn("failed to update state", "error", stateErr)
+        }
         return err
     }
 
+    collected, err := state.CollectResult(ctx, repo.Name, filepath.Join(s.WorkDir, repo.Name))
+    if err != nil {
+        log.Warn("failed to collect sync results", "error", err)
+    }
+    collected.Missing = skipped
+    if err := s.Store.Complete(repo.Name, state.PhaseSync, collected); err != nil {
+        log.Warn("failed to update state", "error", err)
+    }
+
+    // git lfs push offers every local object, the server skips the ones it already has
+    result.Status = common.StatusSuccess
+    result.Refs = len(collected.Refs)
+    result.Objects = collected.Objects
+    result.Bytes = collected.Bytes
+    result.Missing = skipped
+    if len(skipped) > 0 {
+        log.Warn("pushed an incomplete set of LFS objects, skipped objects missing locally", "objects", len(skipped))
+    }
+    log.Info("sync completed", "refs", result.Refs, "objects", result.Objects, "bytes", result.Bytes, "duration", time.Since(result.StartTime).Round(time.Millisecond).String())
+    return nil
+}
+
+// SyncLFSContentMirrorMode pushes the LFS objects of a mirror clone, leaving
+// out the excluded ones. With allowIncomplete, objects missing locally are
+// skipped and returned.
+func SyncLFSContentMirrorMode(ctx context.Context, repoName, workDir, targetOrg, hostname, token string, refs common.RefFilter, allowIncomplete bool, exclude map[string]bool) ([]common.MissingObject, error) {
+    repoPath := filepath.Join(workDir, repoName)
+    log := logger.ForRepo(repoName, state.PhaseSync)
+
+    if err := configureGitAuth(ctx, hostname, token); err != nil {
+        return nil, err
+    }
+
     // Set environment variables
-    env := setupGitEnv()
+    env := setupGitEnv(allowIncomplete)
 
-    fmt.Printf("Syncing %s to %s/%s...\n", repoName, targetOrg, repoName)
+    log.Info("syncing repository", "target", fmt.Sprintf("%s/%s", targetOrg, repoName))
 
     // Set the remote URL without embedding the token
-    baseURL := fmt.Sprintf("https://github.com/%s/%s.git", targetOrg, repoName)
-    if err := setAndVerifyRemote(repoPath, baseURL, env); err != nil {
-        return err
+    baseURL := common.RepositoryURL(hostname, targetOrg, repoName)
+    git := common.NewGitCommand(ctx, repoName, repoPath, env)
+    if err := setAndVerifyRemote(log, git, baseURL); err != nil {
+        return nil, err
     }
 
-    // Push all LFS content
-    lfsPushCmd := exec.Command("git", "lfs", "push", "--all", "origin")
-    lfsPushCmd.Dir = repoPath
-    lfsPushCmd.Env = env
-    if output, err := lfsPushCmd.CombinedOutput(); err != nil {
-        errMsg := strings.ReplaceAll(string(output), token, "****")
-        return fmt.Errorf("failed to push LFS content: %s, %w", errMsg, err)
+    checkpoint, err := common.OpenCheckpoint(workDir, repoName, state.PhaseSync, baseURL)
+    if err != nil {
+        return nil, err
     }
+    defer checkpoint.Close()
 
-    fmt.Printf("Successfully synced content for %s\n", repoName)
-    return nil
+    // Push the LFS content of all refs, or of the refs selected by the filters
+    var selected []string
+    if !refs.IsZero() {
+        if selected, err = refs.SelectRefs(git); err != nil {
+            return nil, err
+        }
+        if len(selected) == 0 {
+            log.Warn("no refs match the ref filters, nothing to push")
+            return nil, nil
+        }
+        log.Info("pushing LFS objects of selected refs", "refs", len(selected))
+    }
+
+    var skipped *skippedObjects
+    if allowIncomplete {
+        skipped = &skippedObjects{}
+    }
+
+    if pointers, err := common.ListLFSPointers(git, selected...); err != nil {
+        if len(exclude) > 0 {
+            return nil, fmt.Errorf("cannot leave out oversized objects: %w", err)
+        }
+        log.Warn("failed to list LFS objects, pushing without checkpoints", "error", err)
+        if output, err := git.CombinedOutput(append([]string{"lfs", "push", "--all", "origin"}, selected...)...); err != nil {
+            errMsg := redact.String(string(output))
+            return nil, fmt.Errorf("failed to push LFS content: %s, %w", errMsg, err)
+        }
+    } else {
+        oids, absent := splitLocalObjects(repoPath, pointers, exclude)
+        if len(absent) > 0 {
+            if skipped == nil {
+                return nil, missingLocallyError(absent)
+            }
+            if err := skipped.addRefs(log, git, absent, selected); err != nil {
+                return nil, err
+            }
+        }
+        if err := pushObjects(log, git, checkpoint, oids); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := checkpoint.Remove(); err != nil {
+        log.Warn("failed to remove checkpoint", "error", err)
+    }
+    log.Info("successfully synced content")
+    return skipped.list(), nil
 }
 
-func SyncLFSContentBranchMode(repoName, workDir, targetOrg, token string) error {
+// SyncLFSContentBranchMode pushes the LFS objects of a working clone ref by
+// ref, leaving out the excluded ones. With allowIncomplete, objects missing
+// locally are skipped and returned.
+func SyncLFSContentBranchMode(ctx context.Context, repoName, workDir, targetOrg, hostname, token string, refs common.RefFilter, tagOrder string, allowIncomplete bool, exclude map[string]bool) ([]common.MissingObject, error) {
     repoPath := filepath.Join(workDir, repoName)
+    log := logger.ForRepo(repoName, state.PhaseSync)
 
-    if err := configureGitAuth(token); err != nil {
-        return err
+    if err := configureGitAuth(ctx, hostname, token); err != nil {
+        return nil, err
     }
 
     // Set environment variables
-    env := setupGitEnv()
+    env := setupGitEnv(allowIncomplete)
 
-    fmt.Printf("Syncing %s to %s/%s...\n", repoName, targetOrg, repoName)
+    log.Info("syncing repository", "target", fmt.Sprintf("%s/%s", targetOrg, repoName))
 
     // Set the remote URL without embedding the token
-    baseURL := fmt.Sprintf("https://github.com/%s/%s.git", targetOrg, repoName)
-    if err := setAndVerifyRemote(repoPath, baseURL, env); err != nil {
-        return err
+    baseURL := common.RepositoryURL(hostname, targetOrg, repoName)
+    git := common.NewGitCommand(ctx, repoName, repoPath, env)
+    if err := setAndVerifyRemote(log, git, baseURL); err != nil {
+        return nil, err
     }
 
+    checkpoint, err := common.OpenCheckpoint(workDir, repoName, state.PhaseSync, baseURL)
+    if err != nil {
+        return nil, err
+    }
+    defer checkpoint.Close()
+
     // Get the default branch using symbolic-ref
-    defaultBranchCmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
-    defaultBranchCmd.Dir = repoPath
-    output, err := defaultBranchCmd.Output()
+    output, err := git.Output("symbolic-ref", "refs/remotes/origin/HEAD")
     if err != nil {
-        return fmt.Errorf("failed to get default branch: %w", err)
+        return nil, fmt.Errorf("failed to get default branch: %w", err)
     }
     defaultBranch := strings.TrimPrefix(
         strings.TrimSpace(string(output)),
         "refs/remotes/origin/",
     )
 
-    // Get list of all remote branches
-    branchCmd := exec.Command("git", "for-each-ref", "--format=%(refname)", "refs/remotes/origin")
-    branchCmd.Dir = repoPath
-    output, err = branchCmd.Output()
+    // Get list of the remote branches selected by the ref filters
+    selected, err := refs.SelectRefs(git)
     if err != nil {
-        return fmt.Errorf("failed to list branches: %w", err)
+        return nil, fmt.Errorf("failed to list branches: %w", err)
+    }
+
+    var skipped *skippedObjects
+    if allowIncomplete {
+        skipped = &skippedObjects{}
     }
 
     // Process branches, starting with default branch
     branches := []string{}
-    scanner := bufio.NewScanner(strings.NewReader(string(output)))
-    for scanner.Scan() {
-        branch := scanner.Text()
-        if strings.HasSuffix(branch, "/HEAD") {
+    tags := []string{}
+    hasDefault := false
+    for _, ref := range selected {
+        if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+            tags = append(tags, tag)
+            continue
+        }
+        branchName, ok := strings.CutPrefix(ref, "refs/remotes/origin/")
+        if !ok {
             continue
         }
-        branchName := strings.TrimPrefix(branch, "refs/remotes/origin/")
-        if branchName != defaultBranch {
+        if branchName == defaultBranch {
+            hasDefault = true
+        } else {
             branches = append(branches, branchName)
         }
     }
 
     // Process default branch first
-    if err := processBranch(repoPath, defaultBranch, env, token); err != nil {
-        return err
+    if hasDefault {
+        if err := processRef(log, git, checkpoint, exclude, skipped, "branch", defaultBranch); err != nil {
+            return nil, err
+        }
+    } else {
+        log.Info("default branch excluded by the ref filters", "branch", defaultBranch)
+    }
+
+    processTags := func() error {
+        for _, tag := range tags {
+            if err := processRef(log, git, checkpoint, exclude, skipped, "tag", tag); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    if tagOrder == TagsBeforeBranches {
+        if err := processTags(); err != nil {
+            return nil, err
+        }
     }
 
     // Process remaining branches
     for _, branchName := range branches {
-        if err := processBranch(repoPath, branchName, env, token); err != nil {
-            return err
+        if err := processRef(log, git, checkpoint, exclude, skipped, "branch", branchName); err != nil {
+            return nil, err
         }
     }
 
+    if tagOrder == TagsAfterBranches {
+        if err := processTags(); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := checkpoint.Remove(); err != nil {
+        log.Warn("failed to remove checkpoint", "error", err)
+    }
+    return skipped.list(), nil
+}
+
+// processRef pushes the LFS objects in the history of a branch or tag, leaving
+// out the excluded ones. Branches are read from the pulled remote-tracking
+// refs, so they are never checked out. Objects missing locally are recorded in
+// skipped, or fail the push when it is nil.
+func processRef(log *slog.Logger, git *common.GitCommand, checkpoint *common.Checkpoint, exclude map[string]bool, skipped *skippedObjects, kind, name string) error {
+    ref := "refs/tags/" + name
+    if kind == "branch" {
+        ref = "refs/remotes/origin/" + name
+    }
+
+    // Push LFS content for this ref
+    if pointers, err := common.ListLFSPointers(git, ref); err != nil {
+        if len(exclude) > 0 {
+            return fmt.Errorf("%s %s: cannot leave out oversized objects: %w", kind, name, err)
+        }
+        log.Warn("failed to list LFS objects, pushing without checkpoints", kind, name, "error", err)
+        if output, err := git.CombinedOutput("lfs", "push", "origin", ref, "--all"); err != nil {
+            errMsg := redact.String(string(output))
+            return fmt.Errorf("failed to push LFS content for %s %s: %s, %w", kind, name, errMsg, err)
+        }
+    } else {
+        oids, absent := splitLocalObjects(git.Dir, pointers, exclude)
+        if len(absent) > 0 {
+            if skipped == nil {
+                return fmt.Errorf("%s %s: %w", kind, name, missingLocallyError(absent))
+            }
+            source, _ := common.SourceRef(ref, true)
+            skipped.add(absent, source)
+        }
+        if err := pushObjects(log, git, checkpoint, oids); err != nil {
+            return fmt.Errorf("%s %s: %w", kind, name, err)
+        }
+    }
+
+    log.Info("successfully synced content for "+kind, kind, name)
     return nil
 }
 
-// Helper function to process a single branch
-func processBranch(repoPath, branchName string, env []string, token string) error {
-    // Checkout branch
-    checkoutCmd := exec.Command("git", "checkout", branchName)
-    checkoutCmd.Dir = repoPath
-    if output, err := checkoutCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("failed to checkout branch %s: %s, %w", branchName, string(output), err)
+// pushObjects pushes the objects among oids that the checkpoint does not list
+// yet, in chunks that are recorded as soon as the target has them
+func pushObjects(log *slog.Logger, git *common.GitCommand, checkpoint *common.Checkpoint, oids []string) error {
+    pending := checkpoint.Pending(oids)
+    if done := len(oids) - len(pending); done > 0 {
+        log.Info("resuming push, skipping objects pushed before", "objects", done, "remaining", len(pending))
     }
 
-    // Reset and clean
-    resetCmd := exec.Command("git", "reset", "--hard")
-    resetCmd.Dir = repoPath
-    if output, err := resetCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("failed to reset branch %s: %s, %w", branchName, string(output), err)
+    for _, chunk := range common.ChunkObjects(git.Dir, pending, pushChunkObjects, pushChunkBytes) {
+        args := append([]string{"lfs", "push", "origin", "--object-id"}, chunk...)
+        if output, err := git.CombinedOutput(args...); err != nil {
+            errMsg := redact.String(string(output))
+            return fmt.Errorf("failed to push LFS content: %s, %w", errMsg, err)
+        }
+        if err := checkpoint.Record(chunk); err != nil {
+            log.Warn("failed to update checkpoint", "error", err)
+        }
+    }
+    return nil
+}
+
+// verifyObjects re-hashes the local LFS objects of a repository when Verify is
+// set, returning the corrupt ones it quarantined
+func (s *Syncer) verifyObjects(ctx context.Context, log *slog.Logger, repoName string) ([]common.CorruptObject, error) {
+    if !s.Verify {
+        return nil, nil
     }
 
-    cleanCmd := exec.Command("git", "clean", "-f", "-d")
-    cleanCmd.Dir = repoPath
-    if output, err := cleanCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("failed to clean branch %s: %s, %w", branchName, string(output), err)
+    verified, err := common.VerifyLFSObjects(ctx, s.WorkDir, repoName)
+    if err != nil {
+        return nil, err
+    }
+    for _, object := range verified.Corrupt {
+        log.Warn("quarantined corrupt LFS object", "oid", object.OID, "size", common.FormatBytes(object.Size), "sha256", object.SHA256)
+    }
+    if len(verified.Corrupt) > 0 {
+        log.Warn("corrupt LFS objects are missing locally until pulled again", "objects", len(verified.Corrupt))
     }
+    log.Debug("verified local LFS objects", "objects", verified.Objects, "bytes", common.FormatBytes(verified.Bytes))
+    return verified.Corrupt, nil
+}
 
-    // Push LFS content for this branch
-    lfsPushCmd := exec.Command("git", "lfs", "push", "origin", branchName, "--all")
-    lfsPushCmd.Dir = repoPath
-    lfsPushCmd.Env = env
-    if output, err := lfsPushCmd.CombinedOutput(); err != nil {
-        errMsg := strings.ReplaceAll(string(output), token, "****")
-        return fmt.Errorf("failed to push LFS content for branch %s: %s, %w", branchName, errMsg, err)
+// checkObjectSizes reports the LFS objects of the refs to push larger than
+// MaxObjectSize, reading their size from the pointers so that objects missing
+// or truncated locally are checked too. They fail the repository with
+// ErrOversized, or are returned to be left out of the push under the skip
+// policy.
+func (s *Syncer) checkObjectSizes(ctx context.Context, log *slog.Logger, repoName string) ([]common.OversizedObject, error) {
+    if s.MaxObjectSize <= 0 {
+        return nil, nil
     }
 
-    fmt.Printf("Successfully synced content for branch %s\n", branchName)
+    git := common.NewGitCommand(ctx, repoName, filepath.Join(s.WorkDir, repoName), nil)
+    refs, err := s.pushedRefs(git)
+    if err != nil || len(refs) == 0 {
+        return nil, err
+    }
+    pointers, err := common.ListLFSPointers(git, refs...)
+    if err != nil {
+        return nil, err
+    }
+    oversized := common.FindOversizedObjects(pointers, s.MaxObjectSize)
+    if len(oversized) == 0 {
+        return nil, nil
+    }
+
+    for _, object := range oversized {
+        log.Warn("LFS object larger than the target accepts", "oid", object.OID, "path", object.Path,
+            "size", common.FormatBytes(object.Size), "limit", common.FormatBytes(s.MaxObjectSize))
+    }
+
+    if s.OversizedPolicy == OversizedSkip {
+        log.Warn("skipping oversized LFS objects", "objects", len(oversized))
+        return oversized, nil
+    }
+    return oversized, fmt.Errorf("%w: %d above %s, such as %s at %s; use --oversized-policy skip to push the others",
+        common.ErrOversized, len(oversized), common.FormatBytes(s.MaxObjectSize), oversized[0].OID, oversized[0].Path)
+}
+
+// pushedRefs returns the refs whose LFS objects sync pushes
+func (s *Syncer) pushedRefs(git *common.GitCommand) ([]string, error) {
+    selected, err := s.Refs.SelectRefs(git)
+    if err != nil || !s.BranchMode {
+        return selected, err
+    }
+
+    // Branch mode pushes the remote-tracking branches, and tags unless left out
+    var refs []string
+    for _, ref := range selected {
+        if strings.HasPrefix(ref, "refs/remotes/origin/") || (s.TagOrder != TagsNone && strings.HasPrefix(ref, "refs/tags/")) {
+            refs = append(refs, ref)
+        }
+    }
+    return refs, nil
+}
+
+// splitLocalObjects splits the objects of the pointers that are not excluded
+// into those present locally and the pointers of those that are not
+func splitLocalObjects(repoPath string, pointers []common.LFSPointer, exclude map[string]bool) ([]string, []common.LFSPointer) {
+    seen := make(map[string]bool)
+    var oids []string
+    var absent []common.LFSPointer
+    for _, pointer := range pointers {
+        if exclude[pointer.OID] {
+            continue
+        }
+        if !common.HasLFSObject(repoPath, pointer.OID) {
+            absent = append(absent, pointer)
+        } else if !seen[pointer.OID] {
+            seen[pointer.OID] = true
+            oids = append(oids, pointer.OID)
+        }
+    }
+    return oids, absent
+}
+
+func missingLocallyError(absent []common.LFSPointer) error {
+    oids := make(map[string]bool)
+    for _, pointer := range absent {
+        oids[pointer.OID] = true
+    }
+    return fmt.Errorf("%d LFS objects are missing locally, such as %s at %s; pull again or use --allow-incomplete-push to push the others",
+        len(oids), absent[0].OID, absent[0].Path)
+}
+
+// skippedObjects collects the objects an incomplete push leaves out, with the
+// paths and refs that reference them
+type skippedObjects struct {
+    objects []*common.MissingObject
+    index   map[string]*common.MissingObject
+}
+
+// add records the objects of pointers found in the history of ref
+func (s *skippedObjects) add(pointers []common.LFSPointer, ref string) {
+    if s.index == nil {
+        s.index = make(map[string]*common.MissingObject)
+    }
+    for _, pointer := range pointers {
+        object, ok := s.index[pointer.OID]
+        if !ok {
+            object = &common.MissingObject{OID: pointer.OID, Path: pointer.Path}
+            s.index[pointer.OID] = object
+            s.objects = append(s.objects, object)
+        }
+        if ref != "" && !slices.Contains(object.Refs, ref) {
+            object.Refs = append(object.Refs, ref)
+        }
+    }
+}
+
+// addRefs records the objects of pointers listed for all of refs, or for all
+// refs of the repository when none are given, looking up which refs reference
+// each of them
+func (s *skippedObjects) addRefs(log *slog.Logger, git *common.GitCommand, pointers []common.LFSPointer, refs []string) error {
+    s.add(pointers, "")
+    if len(refs) == 0 {
+        var err error
+        if refs, err = (common.RefFilter{}).SelectRefs(git); err != nil {
+            return err
+        }
+    }
+
+    log.Info("finding the refs referencing objects missing locally", "objects", len(s.index), "refs", len(refs))
+    for _, ref := range refs {
+        refPointers, err := common.ListLFSPointers(git, ref)
+        if err != nil {
+            return err
+        }
+        var referenced []common.LFSPointer
+        for _, pointer := range refPointers {
+            if _, ok := s.index[pointer.OID]; ok {
+                referenced = append(referenced, pointer)
+            }
+        }
+        s.add(referenced, ref)
+    }
     return nil
 }
 
-func configureGitAuth(token string) error {
-    // Configure GitHub authentication
-    authCmd := exec.Command("sh", "-c", fmt.Sprintf("echo %q | gh auth login --with-token", token))
+// list returns the skipped objects, nil when there are none
+func (s *skippedObjects) list() []common.MissingObject {
+    if s == nil {
+        return nil
+    }
+    var objects []common.MissingObject
+    for _, object := range s.objects {
+        objects = append(objects, *object)
+    }
+    return objects
+}
+
+func configureGitAuth(ctx context.Context, hostname, token string) error {
+    // Configure GitHub authentication for the target host
+    authCmd := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf("echo %q | gh auth login --hostname %q --with-token", token, common.GitHubHost(hostname)))
     if output, err := authCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("❌ Failed to configure GitHub authentication: %s, %w", string(output), err)
+        return fmt.Errorf("❌ Failed to configure GitHub authentication: %s, %w", redact.String(string(output)), err)
     }
 
     // Configure git credential helper
-    credCmd := exec.Command("git", "config", "--global", "credential.helper", "!gh auth git-credential")
+    credCmd := exec.CommandContext(ctx, "git", "config", "--global", "credential.helper", "!gh auth git-credential")
     if output, err := credCmd.CombinedOutput(); err != nil {
-        return fmt.Errorf("❌ Failed to configure git credential helper: %s, %w", string(output), err)
+        return fmt.Errorf("❌ Failed to configure git credential helper: %s, %w", redact.String(string(output)), err)
     }
 
     return nil
 }
 
-func setAndVerifyRemote(repoPath, baseURL string, env []string) error {
+func setAndVerifyRemote(log *slog.Logger, git *common.GitCommand, baseURL string) error {
     // Set the remote URL
-    remoteCmd := exec.Command("git", "remote", "set-url", "origin", baseURL)
-    remoteCmd.Dir = repoPath
-    remoteCmd.Env = env
-    if err := remoteCmd.Run(); err != nil {
+    if err := git.Run("remote", "set-url", "origin", baseURL); err != nil {
         return fmt.Errorf("failed to set remote url: %w", err)
     }
 
     // Verify the remote URL
-    verifyCmd := exec.Command("git", "remote", "get-url", "origin")
-    verifyCmd.Dir = repoPath
-    verifyCmd.Env = env
-    output, err := verifyCmd.Output()
+    output, err := git.Output("remote", "get-url", "origin")
     if err != nil {
         return fmt.Errorf("failed to get remote url: %w", err)
     }
     remoteURL := strings.TrimSpace(string(output))
-    fmt.Printf("remote URL: %s\n", remoteURL)
+    log.Debug("remote URL set", "url", remoteURL)
 
     return nil
 }
 
 // Helper function to set up git environment variables.
-func setupGitEnv() []string {
-    return append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+func setupGitEnv(allowIncomplete bool) []string {
+    env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+    if allowIncomplete {
+        // Lets git-lfs push skip objects missing locally without changing
+        // the repository config
+        env = appendGitConfig(env, "lfs.allowincompletepush", "true")
+    }
+    return env
+}
+
+// appendGitConfig adds a config entry to env after the GIT_CONFIG_KEY_n and
+// GIT_CONFIG_VALUE_n entries already counted by GIT_CONFIG_COUNT, so that
+// config passed by the operator still applies
+func appendGitConfig(env []string, key, value string) []string {
+    count := 0
+    for _, entry := range env {
+        if n, ok := strings.CutPrefix(entry, "GIT_CONFIG_COUNT="); ok {
+            count, _ = strconv.Atoi(n)
+        }
+    }
+    // The last value of a variable wins, so the new count replaces the old one
+    return append(env,
+        fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key),
+        fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, value),
+        fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1))
 }
\ No newline at end of file
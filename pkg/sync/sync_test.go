@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+func TestParseOversizedPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{"", OversizedFail, false},
+		{"fail", OversizedFail, false},
+		{"skip", OversizedSkip, false},
+		{"ignore", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOversizedPolicy(tt.policy)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseOversizedPolicy(%q) = %q, %v, want %q", tt.policy, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckObjectSizes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	small, large := strings.Repeat("a", 64), strings.Repeat("b", 64)
+
+	// main references the small object, tmp the large one, which is not
+	// present locally
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, output)
+		}
+	}
+	commit := func(name, oid string, size int) {
+		t.Helper()
+		pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, size)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(pointer), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run("add", name)
+		run("commit", "-q", "-m", name)
+	}
+	run("init", "-q", "-b", "main")
+	commit("small.bin", small, 10)
+	run("checkout", "-q", "-b", "tmp")
+	commit("large.bin", large, 11)
+	workDir := t.TempDir()
+	run("clone", "-q", "--mirror", dir, filepath.Join(workDir, "repo"))
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name          string
+		maxObjectSize int64
+		policy        string
+		exclude       string
+		wantOversized int
+		wantErr       bool
+	}{
+		{"no limit", 0, OversizedFail, "", 0, false},
+		{"under the limit", 11, OversizedFail, "", 0, false},
+		{"fail policy", 10, OversizedFail, "", 1, true},
+		{"skip policy", 10, OversizedSkip, "", 1, false},
+		{"ref not pushed", 10, OversizedFail, "refs/heads/tmp", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := common.ParseRefFilter("", tt.exclude, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Syncer{WorkDir: workDir, Refs: refs, MaxObjectSize: tt.maxObjectSize, OversizedPolicy: tt.policy}
+			oversized, err := s.checkObjectSizes(context.Background(), log, "repo")
+			if len(oversized) != tt.wantOversized || (len(oversized) > 0 && oversized[0] != common.OversizedObject{OID: large, Path: "large.bin", Size: 11}) {
+				t.Errorf("oversized = %+v, want %d object(s)", oversized, tt.wantOversized)
+			}
+			if tt.wantErr != errors.Is(err, common.ErrOversized) || (!tt.wantErr && err != nil) {
+				t.Errorf("err = %v, want ErrOversized %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppendGitConfig(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tests := []struct {
+		name string
+		env  []string
+	}{
+		{"no config", nil},
+		{"operator config", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.proxy", "GIT_CONFIG_VALUE_0=http://proxy:3128"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("git", "config", "--get-regexp", `^(http\.proxy|lfs\.allowincompletepush)$`)
+			cmd.Dir = t.TempDir()
+			cmd.Env = appendGitConfig(append([]string{"HOME=" + cmd.Dir}, tt.env...), "lfs.allowincompletepush", "true")
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(output)
+			if !strings.Contains(got, "lfs.allowincompletepush true") ||
+				strings.Contains(got, "http.proxy") != (tt.env != nil) {
+				t.Errorf("git config = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/inventory"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/logger"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/report"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Phase is the name of the verification in logs and reports
+const Phase = "verify"
+
+// VerifyLocal re-hashes the local LFS objects of the repositories in the
+// working directory, or of the ones listed in the inventory file when given.
+// Objects whose content does not match their OID are quarantined and queued
+// to be fetched again by the next pull.
+func VerifyLocal(ctx context.Context) error {
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	inputFile := viper.GetString("GHMLFS_FILE")
+
+	workers, err := common.NewConcurrency(viper.GetInt("GHMLFS_WORKERS"), false, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	var repos []inventory.Repository
+	if inputFile != "" {
+		repos, err = inventory.Read(inputFile)
+	} else {
+		repos, err = localRepositories(workDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan inventory.Repository)
+	go func() {
+		defer close(jobs)
+		for _, repo := range repos {
+			jobs <- repo
+		}
+	}()
+
+	stats := common.NewProcessStats()
+	err = common.WorkerPool(ctx, jobs, len(repos), workers, stats, func(ctx context.Context, repo inventory.Repository) error {
+		return verifyRepository(ctx, workDir, repo, stats)
+	})
+
+	stats.SaveFailures(workDir, Phase)
+	report.Save(viper.GetString("GHMLFS_REPORT"), viper.GetString("GHMLFS_REPORT_FORMAT"), "verify-local", stats)
+	stats.PrintSummary(workDir)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n✅ Verification completed successfully!")
+	return nil
+}
+
+func verifyRepository(ctx context.Context, workDir string, repo inventory.Repository, stats *common.ProcessStats) error {
+	log := logger.ForRepo(repo.Name, Phase)
+	result := common.RepoResult{
+		Repository: repo,
+		Phase:      Phase,
+		StartTime:  time.Now(),
+	}
+	defer func() {
+		result.Duration = time.Since(result.StartTime)
+		stats.RecordResult(result)
+	}()
+
+	ctx, task := common.StartTask(ctx, repo.Name, Phase)
+	defer task.Done()
+
+	verified, err := common.VerifyLFSObjects(ctx, workDir, repo.Name)
+	if err != nil {
+		result.SetError(err)
+		log.Error("verification failed", "error", err, "category", result.Category)
+		return err
+	}
+
+	for _, object := range verified.Corrupt {
+		log.Warn("quarantined corrupt LFS object", "oid", object.OID, "size", common.FormatBytes(object.Size), "sha256", object.SHA256)
+	}
+
+	result.Status = common.StatusSuccess
+	result.Objects = verified.Objects
+	result.Bytes = verified.Bytes
+	result.Corrupt = verified.Corrupt
+	log.Info("verification completed", "objects", result.Objects, "bytes", result.Bytes, "corrupt", len(result.Corrupt), "duration", time.Since(result.StartTime).Round(time.Millisecond).String())
+	return nil
+}
+
+// localRepositories returns the repositories of the working directory that
+// have LFS objects
+func localRepositories(workDir string) ([]inventory.Repository, error) {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read working directory: %w", err)
+	}
+
+	var repos []inventory.Repository
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, err := os.Stat(common.LFSObjectsDir(filepath.Join(workDir, entry.Name()))); err == nil {
+			repos = append(repos, inventory.Repository{Name: entry.Name()})
+		}
+	}
+	if len(repos) == 0 {
+		pterm.Info.Printf("No repositories with LFS objects found in %s\n", workDir)
+	}
+	return repos, nil
+}